@@ -12,8 +12,9 @@ import (
 )
 
 type Master1 struct {
-	Id   int64 `schema:"harness"`
-	Name string
+	Id      int64 `schema:"harness"`
+	Name    string
+	Details []Detail `mappedby:"Master1"`
 }
 
 type Master2 struct {
@@ -40,6 +41,7 @@ type YetAnother struct {
 
 type Config struct {
 	DatabaseConfig tkt.DatabaseConfig `json:"databaseConfig"`
+	PoolConfig     srm.PoolConfig     `json:"poolConfig"`
 }
 
 var Sequences *tkt.Sequences
@@ -80,46 +82,56 @@ func main() {
 	//initDB := InitDB{}
 	//tkt.ExecuteTransactional(config.DatabaseConfig, &initDB)
 
-	mgr := srm.Mgr{DatabaseConfig: config.DatabaseConfig}
-//	mgr.CreateTables([]interface{}{Master1{}, Master2{}, Detail{}, YetAnother{}})
+	mgr := srm.Mgr{DatabaseConfig: config.DatabaseConfig, PoolConfig: config.PoolConfig}
+	defer mgr.Close()
+//	tkt.CheckErr(mgr.CreateTables([]interface{}{Master1{}, Master2{}, Detail{}, YetAnother{}}))
 
-	tx := mgr.StartTransaction()
+	tx, err := mgr.StartTransaction()
+	tkt.CheckErr(err)
 	defer tx.RollbackOnPanic()
 
 	m1 := Master1{Name: "Master 1"}
-	tx.Persist(&m1)
+	tkt.CheckErr(tx.Persist(&m1))
 	m1 = Master1{Name: "Master 1'"}
-	tx.Persist(&m1)
+	tkt.CheckErr(tx.Persist(&m1))
 	m2 := Master2{Name: "Master 2"}
-	tx.Persist(&m2)
+	tkt.CheckErr(tx.Persist(&m2))
 	d := Detail{Name: "Detail", Master1: m1, Master2: m2}
-	tx.Persist(&d)
+	tkt.CheckErr(tx.Persist(&d))
 	ya := YetAnother{Name: "Y A", Detail: d, Time:time.Now(), Date:time.Now(),Double:0.0,Timestamp:time.Now()}
-	tx.Persist(&ya)
+	tkt.CheckErr(tx.Persist(&ya))
 
-	r1 := tx.Query(Detail{}, "where o_Master1.Id = $1 and o_Master2.Id = 2", 1).([]Detail)
+	r1, err := srm.Query[Detail](tx, "where o_Master1.Id = $1 and o_Master2.Id = 2", 1)
+	tkt.CheckErr(err)
 	for i := range r1 {
 		println(r1[i].Name, r1[i].Master1.Name)
 	}
 
-	r2 := tx.Query(YetAnother{}, "where o_Detail_Master1.Id = $1", 1).([]YetAnother)
+	q2, err := tx.Query(YetAnother{}, "where o_Detail_Master1.Id = $1", 1)
+	tkt.CheckErr(err)
+	r2 := q2.([]YetAnother)
 	for i := range r2 {
 		println(r2[i].Name, r2[i].Detail.Master1.Name)
 	}
 
-	p1 := tx.Find(Master1{}, 1).(*Master1)
+	p1, err := srm.Find[Master1](tx, 1)
+	tkt.CheckErr(err)
 	if p1 != nil {
 		println(p1.Name)
 	}
 
-	r3 := tx.Query(Master1{}, "").([]Master1)
+	q3, err := tx.Query(Master1{}, "")
+	tkt.CheckErr(err)
+	r3 := q3.([]Master1)
+	tkt.CheckErr(tx.Load(r3, "Details"))
 	for i := range r3 {
-		println(r3[i].Id, r3[i].Name)
+		println(r3[i].Id, r3[i].Name, len(r3[i].Details))
 	}
 
-	rows := tx.QueryMulti([]interface{}{Master1{}, Detail{}, YetAnother{}},
+	rows, err := tx.QueryMulti([]interface{}{Master1{}, Detail{}, YetAnother{}},
 		srm.Loj("o2.master1_id = o1.id").Loj("o3.detail_id = o2.id"),
 		"order by o1.id")
+	tkt.CheckErr(err)
 	for i := range rows {
 		row := rows[i]
 		m := row[0].(*Master1)
@@ -128,7 +140,7 @@ func main() {
 		println(m.Name, d, ya)
 	}
 
-	tx.Commit()
+	tkt.CheckErr(tx.Commit())
 
 }
 
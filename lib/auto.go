@@ -0,0 +1,107 @@
+package srm
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Clock tells the time written into auto and soft delete fields.
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct {
+}
+
+func (o SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+var clockMux sync.Mutex
+
+var clock Clock = SystemClock{}
+
+// UseClock replaces the system clock, typically with a fixed one in tests.
+func UseClock(c Clock) {
+	clockMux.Lock()
+	defer clockMux.Unlock()
+	clock = c
+}
+
+func now() time.Time {
+	clockMux.Lock()
+	defer clockMux.Unlock()
+	return clock.Now()
+}
+
+// autoIndexes returns the indexes within columnFields of the fields tagged
+// `auto:"<kind>"`, kind being create or update.
+func autoIndexes(objectType reflect.Type, kind string) []int {
+	fields := columnFields(objectType)
+	indexes := make([]int, 0)
+	for i := 1; i < len(fields); i++ {
+		if fields[i].Tag.Get("auto") == kind {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// stampCreated fills the create fields left zero and all the update fields
+// of an entity about to be inserted.
+func stampCreated(object reflect.Value) error {
+	t := now()
+	fields := columnFields(object.Type())
+	for _, i := range autoIndexes(object.Type(), "create") {
+		if object.FieldByIndex(fields[i].Index).IsZero() {
+			if err := stamp(object, fields[i], t); err != nil {
+				return err
+			}
+		}
+	}
+	for _, i := range autoIndexes(object.Type(), "update") {
+		if err := stamp(object, fields[i], t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stampUpdated fills the update fields of an entity about to be updated and
+// returns their indexes.
+func stampUpdated(object reflect.Value) ([]int, error) {
+	t := now()
+	fields := columnFields(object.Type())
+	indexes := autoIndexes(object.Type(), "update")
+	for _, i := range indexes {
+		if err := stamp(object, fields[i], t); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// stamp sets a time.Time, *time.Time or sql.NullTime field to t, truncated to
+// the day for `temporal:"date"` columns.
+func stamp(object reflect.Value, field reflect.StructField, t time.Time) error {
+	valueType, _ := NullableType(field.Type)
+	if field.Type != reflect.TypeOf(t) && valueType != reflect.TypeOf(t) {
+		return fmt.Errorf("%s.%s: auto fields must be times", object.Type().Name(), field.Name)
+	}
+	if field.Tag.Get("temporal") == "date" {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	of := object.FieldByIndex(field.Index)
+	switch {
+	case field.Type == reflect.TypeOf(t):
+		of.Set(reflect.ValueOf(t))
+	case of.Kind() == reflect.Ptr:
+		of.Set(reflect.ValueOf(&t))
+	default:
+		of.Field(0).Set(reflect.ValueOf(t))
+		of.Field(1).SetBool(true)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type autoAudited struct {
+	Id        int64
+	Name      string
+	CreatedAt time.Time  `auto:"create"`
+	UpdatedAt *time.Time `auto:"update"`
+	Version   int64      `version:"true"`
+}
+
+func TestUpdatableColumns(t *testing.T) {
+	objectType := reflect.TypeOf(autoAudited{})
+	cases := []struct {
+		changed  []int
+		version  int
+		expected []int
+	}{
+		{[]int{1, 2, 3, 4}, 4, []int{1, 3}},
+		{[]int{1, 2, 3, 4}, -1, []int{1, 3, 4}},
+		{[]int{2}, 4, []int{}},
+	}
+	for _, c := range cases {
+		if actual := updatableColumns(objectType, c.changed, c.version); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%v: expected %v, got %v", c.changed, c.expected, actual)
+		}
+	}
+}
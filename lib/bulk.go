@@ -0,0 +1,293 @@
+package srm
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"github.com/gabrielmorenobrc/go-tkt/lib"
+)
+
+// Multi-row inserts stay under the bind parameter limit of every engine.
+const (
+	maxBulkParameters = 30000
+	maxBulkRows       = 1000
+)
+
+// BlockIdGenerator is implemented by generators able to reserve many ids in
+// a single round trip.
+type BlockIdGenerator interface {
+	IdGenerator
+	NextIds(ctx context.Context, trx *Trx, objectType reflect.Type, count int) ([]interface{}, error)
+}
+
+// CopyDialect is implemented by dialects able to stream rows with COPY.
+type CopyDialect interface {
+	// CopyIn returns the statement copying into the quoted table and columns
+	// through driver d, or "" when the driver cannot do it.
+	CopyIn(d driver.Driver, table string, columns []string) string
+}
+
+// PersistAll inserts a slice of entities, or of entity pointers, of the same
+// type. Ids are reserved up front, in blocks when the generator supports it,
+// and rows are written with COPY when the driver supports it or with
+// multi-row inserts otherwise.
+func (o *Trx) PersistAll(entities interface{}) error {
+	return o.PersistAllContext(context.Background(), entities)
+}
+
+func (o *Trx) PersistAllContext(ctx context.Context, entities interface{}) error {
+	o.checkMaps()
+	values, objectType, err := entityValues(reflect.ValueOf(entities))
+	if err != nil || len(values) == 0 {
+		return err
+	}
+	generator, err := IdGeneratorFor(objectType)
+	if err != nil {
+		return err
+	}
+	assigned := make([]reflect.Value, 0, len(values))
+	pending := make([]reflect.Value, 0, len(values))
+	for i := range values {
+		if err := o.beforePersist(values[i]); err != nil {
+			return err
+		}
+		if err := o.cascadePersist(ctx, values[i]); err != nil {
+			return err
+		}
+		if err := initVersion(values[i]); err != nil {
+			return err
+		}
+		if err := stampCreated(values[i]); err != nil {
+			return err
+		}
+		if values[i].Field(0).IsZero() {
+			pending = append(pending, values[i])
+		} else {
+			assigned = append(assigned, values[i])
+		}
+	}
+	if isIdentity(generator) {
+		if err := o.persistAllIdentity(ctx, objectType, pending); err != nil {
+			return err
+		}
+		if err := o.insertAll(ctx, objectType, assigned); err != nil {
+			return err
+		}
+		return o.persistedAll(values)
+	}
+	ids, err := nextIds(ctx, o, generator, objectType, len(pending))
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		if err := assignId(pending[i].Field(0), ids[i]); err != nil {
+			return err
+		}
+	}
+	if err := o.insertAll(ctx, objectType, values); err != nil {
+		return err
+	}
+	return o.persistedAll(values)
+}
+
+func (o *Trx) persistedAll(values []reflect.Value) error {
+	for i := range values {
+		o.snapshot(values[i])
+		if err := o.afterPersist(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nextIds(ctx context.Context, trx *Trx, generator IdGenerator, objectType reflect.Type, count int) ([]interface{}, error) {
+	if block, ok := generator.(BlockIdGenerator); ok && count > 1 {
+		return block.NextIds(ctx, trx, objectType, count)
+	}
+	ids := make([]interface{}, count)
+	for i := range ids {
+		id, err := generator.NextId(ctx, trx, objectType)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (o *Trx) persistAllIdentity(ctx context.Context, objectType reflect.Type, values []reflect.Value) error {
+	dialect, ok := o.dialect.(IdentityDialect)
+	if !ok {
+		return fmt.Errorf("dialect %s has no identity columns", o.dialect.Name())
+	}
+	if dialect.Returning(o.column(objectType.Field(0))) != "" {
+		return o.insertBatches(ctx, objectType, values, true)
+	}
+	// Without RETURNING the ids are only known one insert at a time.
+	for i := range values {
+		if err := o.persist(ctx, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Trx) insertAll(ctx context.Context, objectType reflect.Type, values []reflect.Value) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if dialect, ok := o.dialect.(CopyDialect); ok {
+		fields := columnFields(objectType)
+		columns := make([]string, len(fields))
+		for i := range fields {
+			columns[i] = o.column(fields[i])
+		}
+		if sql := dialect.CopyIn(o.db.Driver(), o.table(objectType), columns); sql != "" {
+			return o.copyIn(ctx, sql, values)
+		}
+	}
+	return o.insertBatches(ctx, objectType, values, false)
+}
+
+func (o *Trx) copyIn(ctx context.Context, sql string, values []reflect.Value) error {
+	tkt.Logger("srm").Printf("%s (%d rows)", sql, len(values))
+	stmt, err := o.tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return o.dialect.Classify(err)
+	}
+	defer stmt.Close()
+	for i := range values {
+		if _, err := stmt.ExecContext(ctx, o.insertArgs(values[i])...); err != nil {
+			return o.dialect.Classify(err)
+		}
+	}
+	_, err = stmt.ExecContext(ctx)
+	return o.dialect.Classify(err)
+}
+
+// insertBatches writes values with multi-row inserts. With identity the id
+// column is left to the database and read back, in order, through RETURNING.
+func (o *Trx) insertBatches(ctx context.Context, objectType reflect.Type, values []reflect.Value, identity bool) error {
+	fields := columnFields(objectType)
+	first := 0
+	returning := ""
+	if identity {
+		first = 1
+		returning = o.dialect.(IdentityDialect).Returning(o.column(fields[0]))
+	}
+	rows := maxBulkRows
+	if len(fields) > first && maxBulkParameters/(len(fields)-first) < rows {
+		rows = maxBulkParameters / (len(fields) - first)
+	}
+	header := o.buildBulkInsertSql(objectType, fields[first:], 1)
+	for start := 0; start < len(values); start += rows {
+		end := start + rows
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[start:end]
+		args := make([]interface{}, 0, len(batch)*(len(fields)-first))
+		for i := range batch {
+			args = append(args, o.insertArgs(batch[i])[first:]...)
+		}
+		sql := o.buildBulkInsertSql(objectType, fields[first:], len(batch))
+		tkt.Logger("srm").Printf("%s%s (%d rows)", header, returning, len(batch))
+		if !identity {
+			if _, err := o.tx.ExecContext(ctx, sql, args...); err != nil {
+				return o.dialect.Classify(err)
+			}
+			continue
+		}
+		if err := o.readIdentities(ctx, sql+returning, args, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Trx) readIdentities(ctx context.Context, sql string, args []interface{}, batch []reflect.Value) error {
+	r, err := o.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return o.dialect.Classify(err)
+	}
+	defer r.Close()
+	id := newIdBuffer(batch[0].Field(0).Type())
+	i := 0
+	for ; r.Next(); i++ {
+		if err := r.Scan(id); err != nil {
+			return err
+		}
+		if i < len(batch) {
+			batch[i].Field(0).Set(id.value)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return o.dialect.Classify(err)
+	}
+	if i != len(batch) {
+		return fmt.Errorf("inserted %d rows but %d ids were returned", len(batch), i)
+	}
+	return nil
+}
+
+func (o *Trx) buildBulkInsertSql(objectType reflect.Type, fields []reflect.StructField, count int) string {
+	buffer := strings.Builder{}
+	buffer.WriteString("insert into " + o.table(objectType) + "(")
+	for i := range fields {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(o.column(fields[i]))
+	}
+	buffer.WriteString(") values")
+	p := 1
+	for row := 0; row < count; row++ {
+		if row > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString("(")
+		for i := range fields {
+			if i > 0 {
+				buffer.WriteString(", ")
+			}
+			buffer.WriteString(o.dialect.Placeholder(p))
+			p++
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.String()
+}
+
+func (o Postgres) CopyIn(d driver.Driver, table string, columns []string) string {
+	driverType := reflect.TypeOf(d)
+	if driverType.Kind() == reflect.Ptr {
+		driverType = driverType.Elem()
+	}
+	if driverType.PkgPath() != "github.com/lib/pq" {
+		return ""
+	}
+	return "copy " + table + " (" + strings.Join(columns, ", ") + ") from stdin"
+}
+
+func (o Sequence) NextIds(ctx context.Context, trx *Trx, objectType reflect.Type, count int) ([]interface{}, error) {
+	dialect, ok := trx.dialect.(SequenceDialect)
+	if !ok {
+		return nil, fmt.Errorf("dialect %s has no sequences", trx.dialect.Name())
+	}
+	r, err := trx.tx.QueryContext(ctx, dialect.NextValues(o.sequenceName(objectType), count))
+	if err != nil {
+		return nil, trx.dialect.Classify(err)
+	}
+	defer r.Close()
+	ids := make([]interface{}, 0, count)
+	for r.Next() {
+		var id int64
+		if err := r.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, r.Err()
+}
@@ -0,0 +1,90 @@
+package srm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// cascades reports whether the cascade tag of field, a comma separated list
+// of persist and delete or just all, includes kind.
+func cascades(field reflect.StructField, kind string) bool {
+	tags := strings.Split(field.Tag.Get("cascade"), ",")
+	for i := range tags {
+		tag := strings.TrimSpace(tags[i])
+		if tag == kind || tag == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// cascadePersist persists the entities with a zero id referenced by the
+// relation fields of object tagged to cascade persist, so that their ids can
+// be written as foreign keys. Persisting them cascades in turn.
+func (o *Trx) cascadePersist(ctx context.Context, object reflect.Value) error {
+	columns := columnFields(object.Type())
+	for i := 1; i < len(columns); i++ {
+		field := columns[i]
+		if !IsRelation(field.Type) || !cascades(field, "persist") {
+			continue
+		}
+		of := object.FieldByIndex(field.Index)
+		if of.Kind() == reflect.Ptr {
+			if of.IsNil() {
+				continue
+			}
+			of = of.Elem()
+		}
+		if !of.Field(0).IsZero() {
+			continue
+		}
+		if err := o.PersistContext(ctx, of.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cascadeDelete deletes the rows in the collections of object tagged to
+// cascade delete, before object itself and each through Delete, or
+// HardDelete when hard, so that their own dependents go first. Rows of an
+// object without a soft delete field are always removed, as soft deleted
+// children would still reference them.
+func (o *Trx) cascadeDelete(ctx context.Context, object reflect.Value, hard bool) error {
+	objectType := object.Type()
+	hard = hard || softDeleteIndex(objectType) < 0
+	collections := collectionFields(objectType)
+	for i := range collections {
+		collection := collections[i]
+		if !cascades(collection, "delete") {
+			continue
+		}
+		backRef, err := mappedBy(objectType, collection)
+		if err != nil {
+			return err
+		}
+		elemType := entityType(collection.Type.Elem())
+		args := []interface{}{idValue(object.Field(0)), MaxDepth(0)}
+		if hard {
+			args = append(args, Unscoped())
+		}
+		r, err := o.QueryContext(ctx, reflect.New(elemType).Elem().Interface(), "where o."+o.column(backRef)+" = "+o.dialect.Placeholder(1), args...)
+		if err != nil {
+			return err
+		}
+		arr := reflect.ValueOf(r)
+		for j := 0; j < arr.Len(); j++ {
+			child := arr.Index(j).Addr().Interface()
+			if hard {
+				err = o.HardDeleteContext(ctx, child)
+			} else {
+				err = o.DeleteContext(ctx, child)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
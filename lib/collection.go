@@ -0,0 +1,184 @@
+package srm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// batchSize bounds the number of ids bound to a single IN list.
+const batchSize = 500
+
+// Load fills the collection fields of entities, which may be a pointer to an
+// entity, a slice of entities or of entity pointers, or a pointer to such a
+// slice. With no field names every collection field is loaded. Each field
+// costs one batched IN query per level regardless of the number of owners.
+func (o *Trx) Load(entities interface{}, fields ...string) error {
+	return o.LoadContext(context.Background(), entities, fields...)
+}
+
+func (o *Trx) LoadContext(ctx context.Context, entities interface{}, fields ...string) error {
+	owners, objectType, err := entityValues(reflect.ValueOf(entities))
+	if err != nil {
+		return err
+	}
+	collections := make([]reflect.StructField, 0)
+	if len(fields) == 0 {
+		collections = collectionFields(objectType)
+	}
+	for i := range fields {
+		field, ok := objectType.FieldByName(fields[i])
+		if !ok || !IsCollection(field.Type) {
+			return fmt.Errorf("%s has no collection field %s", objectType.Name(), fields[i])
+		}
+		collections = append(collections, field)
+	}
+	return o.loadCollections(ctx, owners, objectType, collections)
+}
+
+func (o *Trx) loadEager(ctx context.Context, arr reflect.Value, objectType reflect.Type) error {
+	collections := make([]reflect.StructField, 0)
+	all := collectionFields(objectType)
+	for i := range all {
+		if all[i].Tag.Get("fetch") == "eager" {
+			collections = append(collections, all[i])
+		}
+	}
+	if len(collections) == 0 || arr.Len() == 0 {
+		return nil
+	}
+	owners, _, err := entityValues(arr)
+	if err != nil {
+		return err
+	}
+	return o.loadCollections(ctx, owners, objectType, collections)
+}
+
+func (o *Trx) loadCollections(ctx context.Context, owners []reflect.Value, objectType reflect.Type, collections []reflect.StructField) error {
+	ids := make([]interface{}, 0, len(owners))
+	seen := make(map[interface{}]bool)
+	for i := range owners {
+		id := idValue(owners[i].Field(0))
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for i := range collections {
+		collection := collections[i]
+		elemType := entityType(collection.Type.Elem())
+		backRef, err := mappedBy(objectType, collection)
+		if err != nil {
+			return err
+		}
+		children := make(map[interface{}][]reflect.Value)
+		for start := 0; start < len(ids); start += batchSize {
+			end := start + batchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			placeholders := make([]string, end-start)
+			for j := range placeholders {
+				placeholders[j] = o.dialect.Placeholder(j + 1)
+			}
+			conditions := "where o." + o.column(backRef) + " in (" + strings.Join(placeholders, ", ") + ") order by o." + o.column(elemType.Field(0))
+			r, err := o.QueryContext(ctx, reflect.New(elemType).Elem().Interface(), conditions, ids[start:end]...)
+			if err != nil {
+				return err
+			}
+			arr := reflect.ValueOf(r)
+			for j := 0; j < arr.Len(); j++ {
+				child := arr.Index(j)
+				id := relationId(child.FieldByIndex(backRef.Index))
+				if id == nil {
+					continue
+				}
+				children[id] = append(children[id], child)
+			}
+		}
+		for j := range owners {
+			owner := owners[j]
+			values := children[idValue(owner.Field(0))]
+			slice := reflect.MakeSlice(collection.Type, 0, len(values))
+			for k := range values {
+				if IsOptional(collection.Type.Elem()) {
+					slice = reflect.Append(slice, values[k].Addr())
+				} else {
+					slice = reflect.Append(slice, values[k])
+				}
+			}
+			owner.FieldByIndex(collection.Index).Set(slice)
+		}
+	}
+	return nil
+}
+
+// mappedBy resolves the relation field of the collection element pointing back
+// to objectType, named by the mappedby tag or, lacking it, the only such
+// relation.
+func mappedBy(objectType reflect.Type, collection reflect.StructField) (reflect.StructField, error) {
+	elemType := entityType(collection.Type.Elem())
+	name, ok := collection.Tag.Lookup("mappedby")
+	if ok {
+		field, found := elemType.FieldByName(name)
+		if !found || !IsRelation(field.Type) || entityType(field.Type) != objectType {
+			return field, fmt.Errorf("%s.%s: %s has no relation %s to %s", objectType.Name(), collection.Name, elemType.Name(), name, objectType.Name())
+		}
+		return field, nil
+	}
+	candidates := make([]reflect.StructField, 0)
+	columns := columnFields(elemType)
+	for i := range columns {
+		if IsRelation(columns[i].Type) && entityType(columns[i].Type) == objectType {
+			candidates = append(candidates, columns[i])
+		}
+	}
+	if len(candidates) != 1 {
+		return reflect.StructField{}, fmt.Errorf("%s.%s: %d relations from %s to %s, set the mappedby tag", objectType.Name(), collection.Name, len(candidates), elemType.Name(), objectType.Name())
+	}
+	return candidates[0], nil
+}
+
+func collectionFields(objectType reflect.Type) []reflect.StructField {
+	fields := make([]reflect.StructField, 0)
+	for i := 0; i < objectType.NumField(); i++ {
+		field := objectType.Field(i)
+		if IsCollection(field.Type) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func entityValues(value reflect.Value) ([]reflect.Value, reflect.Type, error) {
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	owners := make([]reflect.Value, 0)
+	switch {
+	case value.Kind() == reflect.Struct && value.CanAddr():
+		owners = append(owners, value)
+	case value.Kind() == reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			owner := value.Index(i)
+			if owner.Kind() == reflect.Ptr {
+				if owner.IsNil() {
+					continue
+				}
+				owner = owner.Elem()
+			}
+			owners = append(owners, owner)
+		}
+	default:
+		return nil, nil, fmt.Errorf("expected an entity or a slice of entities, got a %s", value.Kind())
+	}
+	objectType := value.Type()
+	if objectType.Kind() == reflect.Slice {
+		objectType = entityType(objectType.Elem())
+	}
+	if err := checkEntity(objectType); err != nil {
+		return nil, nil, err
+	}
+	return owners, objectType, nil
+}
@@ -0,0 +1,306 @@
+package srm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Criteria builds query conditions over Go field paths instead of SQL
+// aliases. It is passed among the arguments of Query and its variants, in
+// place of the conditions string, and is resolved against the queried entity
+// when the query is built, failing on paths the entity does not have.
+type Criteria struct {
+	condition Condition
+	orders    []Field
+}
+
+// Condition is a predicate over field paths, built from F or combined with
+// And, Or and Not.
+type Condition interface {
+	build(b *criteriaBuilder) error
+}
+
+// Field names a column by its path from the queried entity, such as
+// "Detail.Master1.Name". A path ending in a relation stands for its foreign
+// key.
+type Field struct {
+	path string
+	desc bool
+}
+
+func F(path string) Field {
+	return Field{path: path}
+}
+
+// Where starts a criteria matching all the given conditions.
+func Where(conditions ...Condition) *Criteria {
+	return &Criteria{condition: And(conditions...)}
+}
+
+func (o *Criteria) And(conditions ...Condition) *Criteria {
+	criteria := *o
+	criteria.condition = And(append([]Condition{o.condition}, conditions...)...)
+	return &criteria
+}
+
+func (o *Criteria) Or(conditions ...Condition) *Criteria {
+	criteria := *o
+	criteria.condition = Or(o.condition, And(conditions...))
+	return &criteria
+}
+
+// OrderBy sorts by the given fields, descending for those passed through
+// Field.Desc.
+func (o *Criteria) OrderBy(fields ...Field) *Criteria {
+	criteria := *o
+	criteria.orders = append(append([]Field(nil), o.orders...), fields...)
+	return &criteria
+}
+
+func (o *Criteria) applyQuery(options *queryOptions) {
+	options.criteria = o
+}
+
+func (o Field) Desc() Field {
+	o.desc = true
+	return o
+}
+
+// Eq compares with value, turning into is null when value is nil. Entities
+// are compared by id.
+func (o Field) Eq(value interface{}) Condition {
+	if value == nil {
+		return o.IsNull()
+	}
+	return comparison{o, "=", value}
+}
+
+func (o Field) Ne(value interface{}) Condition {
+	if value == nil {
+		return o.IsNotNull()
+	}
+	return comparison{o, "<>", value}
+}
+
+func (o Field) Lt(value interface{}) Condition {
+	return comparison{o, "<", value}
+}
+
+func (o Field) Le(value interface{}) Condition {
+	return comparison{o, "<=", value}
+}
+
+func (o Field) Gt(value interface{}) Condition {
+	return comparison{o, ">", value}
+}
+
+func (o Field) Ge(value interface{}) Condition {
+	return comparison{o, ">=", value}
+}
+
+func (o Field) Like(pattern string) Condition {
+	return comparison{o, "like", pattern}
+}
+
+// In matches any of values, nothing when there are none.
+func (o Field) In(values ...interface{}) Condition {
+	return inList{o, values}
+}
+
+func (o Field) IsNull() Condition {
+	return nullCheck{o, "is null"}
+}
+
+func (o Field) IsNotNull() Condition {
+	return nullCheck{o, "is not null"}
+}
+
+func And(conditions ...Condition) Condition {
+	return junction{"and", conditions}
+}
+
+func Or(conditions ...Condition) Condition {
+	return junction{"or", conditions}
+}
+
+func Not(condition Condition) Condition {
+	return negation{condition}
+}
+
+type comparison struct {
+	field Field
+	op    string
+	value interface{}
+}
+
+func (o comparison) build(b *criteriaBuilder) error {
+	column, err := b.column(o.field.path)
+	if err != nil {
+		return err
+	}
+	b.sql.WriteString(column + " " + o.op + " " + b.bind(o.value))
+	return nil
+}
+
+type inList struct {
+	field  Field
+	values []interface{}
+}
+
+func (o inList) build(b *criteriaBuilder) error {
+	column, err := b.column(o.field.path)
+	if err != nil {
+		return err
+	}
+	if len(o.values) == 0 {
+		b.sql.WriteString("1 = 0")
+		return nil
+	}
+	placeholders := make([]string, len(o.values))
+	for i := range o.values {
+		placeholders[i] = b.bind(o.values[i])
+	}
+	b.sql.WriteString(column + " in (" + strings.Join(placeholders, ", ") + ")")
+	return nil
+}
+
+type nullCheck struct {
+	field Field
+	op    string
+}
+
+func (o nullCheck) build(b *criteriaBuilder) error {
+	column, err := b.column(o.field.path)
+	if err != nil {
+		return err
+	}
+	b.sql.WriteString(column + " " + o.op)
+	return nil
+}
+
+type junction struct {
+	op         string
+	conditions []Condition
+}
+
+func (o junction) build(b *criteriaBuilder) error {
+	if len(o.conditions) == 0 {
+		if o.op == "and" {
+			b.sql.WriteString("1 = 1")
+		} else {
+			b.sql.WriteString("1 = 0")
+		}
+		return nil
+	}
+	b.sql.WriteString("(")
+	for i := range o.conditions {
+		if i > 0 {
+			b.sql.WriteString(" " + o.op + " ")
+		}
+		if err := o.conditions[i].build(b); err != nil {
+			return err
+		}
+	}
+	b.sql.WriteString(")")
+	return nil
+}
+
+type negation struct {
+	condition Condition
+}
+
+func (o negation) build(b *criteriaBuilder) error {
+	b.sql.WriteString("not (")
+	if err := o.condition.build(b); err != nil {
+		return err
+	}
+	b.sql.WriteString(")")
+	return nil
+}
+
+type criteriaBuilder struct {
+	trx        *Trx
+	objectType reflect.Type
+	plan       *fetchPlan
+	sql        strings.Builder
+	args       []interface{}
+}
+
+// build renders the criteria as the conditions of a query on objectType
+// joined after plan, numbering its placeholders after args.
+func (o *Criteria) build(trx *Trx, objectType reflect.Type, plan *fetchPlan, args []interface{}) (string, []interface{}, error) {
+	b := &criteriaBuilder{trx: trx, objectType: objectType, plan: plan, args: args}
+	b.sql.WriteString("where ")
+	if err := o.condition.build(b); err != nil {
+		return "", nil, err
+	}
+	for i := range o.orders {
+		if i == 0 {
+			b.sql.WriteString(" order by ")
+		} else {
+			b.sql.WriteString(", ")
+		}
+		column, err := b.column(o.orders[i].path)
+		if err != nil {
+			return "", nil, err
+		}
+		b.sql.WriteString(column)
+		if o.orders[i].desc {
+			b.sql.WriteString(" desc")
+		}
+	}
+	return b.sql.String(), b.args, nil
+}
+
+// column resolves a field path to the aliased column of the query, the alias
+// of each relation being the one given by buildMtoJoins. The id of a relation
+// that is not joined is read from the foreign key instead.
+func (o *criteriaBuilder) column(path string) (string, error) {
+	parts := strings.Split(path, ".")
+	objectType := o.objectType
+	plan := o.plan
+	alias := "o"
+	for i := range parts {
+		field, ok := objectType.FieldByName(parts[i])
+		if !ok || len(field.Index) > 1 || field.PkgPath != "" || IsCollection(field.Type) {
+			return "", fmt.Errorf("%s: %s has no column field %s", path, objectType.Name(), parts[i])
+		}
+		if i == len(parts)-1 {
+			return alias + "." + o.trx.column(field), nil
+		}
+		if !IsRelation(field.Type) {
+			return "", fmt.Errorf("%s: %s.%s is not a relation", path, objectType.Name(), parts[i])
+		}
+		if !plan.fetches(parts[i]) {
+			if i == len(parts)-2 && parts[i+1] == entityType(field.Type).Field(0).Name {
+				return alias + "." + o.trx.column(field), nil
+			}
+			return "", fmt.Errorf("%s: %s.%s is not fetched", path, objectType.Name(), parts[i])
+		}
+		plan = plan.child(parts[i])
+		alias += "_" + parts[i]
+		objectType = entityType(field.Type)
+	}
+	return "", fmt.Errorf("empty field path")
+}
+
+// bind adds value to the arguments and returns its placeholder.
+func (o *criteriaBuilder) bind(value interface{}) string {
+	o.args = append(o.args, bindValue(value))
+	return o.trx.dialect.Placeholder(len(o.args))
+}
+
+// bindValue turns entities into their id and UUID arrays into what idValue
+// binds for them.
+func bindValue(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	switch {
+	case value == nil:
+	case IsRelation(v.Type()):
+		return relationId(v)
+	case IsUUIDType(v.Type()):
+		return idValue(v)
+	}
+	return value
+}
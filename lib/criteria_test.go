@@ -0,0 +1,93 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type criteriaMaster struct {
+	Id   int64
+	Name string
+}
+
+type criteriaDetail struct {
+	Id     int64
+	Master criteriaMaster
+	Other  *criteriaMaster
+	Name   string
+}
+
+type criteriaLine struct {
+	Id     int64
+	Detail criteriaDetail
+	Lines  []criteriaLine
+	Amount float64
+	hidden string
+}
+
+func TestCriteriaColumn(t *testing.T) {
+	cases := []struct {
+		options  []interface{}
+		path     string
+		expected string
+	}{
+		{nil, "Amount", `o."amount"`},
+		{nil, "Detail", `o."detail_id"`},
+		{nil, "Detail.Name", `o_Detail."name"`},
+		{nil, "Detail.Master.Name", `o_Detail_Master."name"`},
+		{nil, "Detail.Other.Id", `o_Detail_Other."id"`},
+		{[]interface{}{MaxDepth(1)}, "Detail.Master.Id", `o_Detail."master_id"`},
+		{[]interface{}{MaxDepth(0)}, "Detail.Id", `o."detail_id"`},
+		{[]interface{}{Fetch("Detail.Other")}, "Detail.Other.Name", `o_Detail_Other."name"`},
+		{[]interface{}{Fetch("Detail.Other")}, "Detail.Master.Id", `o_Detail."master_id"`},
+	}
+	for _, c := range cases {
+		_, options := splitArgs(c.options)
+		b := &criteriaBuilder{trx: &Trx{dialect: Postgres{}}, objectType: reflect.TypeOf(criteriaLine{}), plan: options.plan}
+		actual, err := b.column(c.path)
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.path, c.expected, actual)
+		}
+	}
+}
+
+func TestCriteriaColumnErrors(t *testing.T) {
+	cases := []struct {
+		options []interface{}
+		path    string
+	}{
+		{nil, ""},
+		{nil, "Missing"},
+		{nil, "hidden"},
+		{nil, "Lines"},
+		{nil, "Amount.Value"},
+		{nil, "Detail.Missing"},
+		{[]interface{}{MaxDepth(0)}, "Detail.Name"},
+		{[]interface{}{MaxDepth(1)}, "Detail.Master.Name"},
+	}
+	for _, c := range cases {
+		_, options := splitArgs(c.options)
+		b := &criteriaBuilder{trx: &Trx{dialect: Postgres{}}, objectType: reflect.TypeOf(criteriaLine{}), plan: options.plan}
+		if actual, err := b.column(c.path); err == nil {
+			t.Errorf("%q: expected an error, got %s", c.path, actual)
+		}
+	}
+}
+
+func TestCriteriaBuild(t *testing.T) {
+	criteria := Where(F("Name").Eq("a"), Or(F("Master").Eq(criteriaMaster{Id: 3}), F("Other").IsNull()), F("Id").In()).
+		OrderBy(F("Master.Name").Desc(), F("Id"))
+	trx := &Trx{dialect: Postgres{}}
+	actual, args, err := criteria.build(trx, reflect.TypeOf(criteriaDetail{}), nil, []interface{}{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `where (o."name" = $2 and (o."master_id" = $3 or o."other_id" is null) and 1 = 0) order by o_Master."name" desc, o."id"`
+	if actual != expected || !reflect.DeepEqual(args, []interface{}{1, "a", int64(3)}) {
+		t.Errorf("expected %s, got %s %v", expected, actual, args)
+	}
+}
@@ -0,0 +1,321 @@
+package srm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUniqueViolation     = errors.New("unique violation")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrNotNullViolation    = errors.New("not null violation")
+)
+
+// Dialect isolates the SQL that differs between database engines.
+type Dialect interface {
+	Name() string
+	// Placeholder returns the bind parameter for the 1-based argument index.
+	Placeholder(index int) string
+	Quote(identifier string) string
+	ColumnType(field reflect.StructField) string
+	Paginate(limit string, offset string) string
+	// Classify wraps err with one of the ErrXxxViolation sentinels when it
+	// recognizes the failure, otherwise it returns err unchanged.
+	Classify(err error) error
+}
+
+var dialectMux sync.Mutex
+
+var dialects = map[string]Dialect{
+	"postgres": Postgres{},
+	"pgx":      Postgres{},
+	"mysql":    MySQL{},
+	"sqlite3":  SQLite{},
+	"sqlite":   SQLite{},
+}
+
+func RegisterDialect(driver string, dialect Dialect) {
+	dialectMux.Lock()
+	defer dialectMux.Unlock()
+	dialects[driver] = dialect
+}
+
+func DialectFor(driver string) (Dialect, error) {
+	dialectMux.Lock()
+	defer dialectMux.Unlock()
+	dialect, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("no dialect registered for driver %q", driver)
+	}
+	return dialect, nil
+}
+
+type typeNames struct {
+	integer   string
+	bigint    string
+	float     string
+	double    string
+	boolean   string
+	varchar   string
+	binary    string
+	timestamp string
+	uuid      string
+}
+
+func mapColumnType(dialect Dialect, names typeNames, field reflect.StructField) string {
+	t := field.Type
+	if valueType, ok := NullableType(t); ok && !IsRelation(t) {
+		field.Type = valueType
+		return mapColumnType(dialect, names, field)
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		temporal, ok := field.Tag.Lookup("temporal")
+		if !ok || temporal == "timestamp" {
+			return names.timestamp
+		}
+		return temporal
+	case IsRelation(t):
+		idField, _ := entityType(t).FieldByName("Id")
+		return dialect.ColumnType(idField)
+	case IsReference(t):
+		idField, _ := referenceTarget(t).FieldByName("Id")
+		return dialect.ColumnType(idField)
+	case t == reflect.TypeOf(big.Float{}):
+		return "numeric(" + field.Tag.Get("precision") + ")"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int16, reflect.Int8:
+		return names.integer
+	case reflect.Int64:
+		return names.bigint
+	case reflect.Float32:
+		return names.float
+	case reflect.Float64:
+		return names.double
+	case reflect.Bool:
+		return names.boolean
+	case reflect.String:
+		len, ok := field.Tag.Lookup("len")
+		if !ok {
+			len = "255"
+		}
+		return names.varchar + "(" + len + ")"
+	case reflect.Array:
+		if IsUUIDType(t) {
+			return names.uuid
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			precision, ok := field.Tag.Lookup("precision")
+			if ok {
+				return "numeric(" + precision + ")"
+			}
+			return names.binary
+		}
+	}
+	return ""
+}
+
+func quoteWith(identifier string, quote string) string {
+	return quote + strings.ReplaceAll(identifier, quote, quote+quote) + quote
+}
+
+func paginate(limit string, offset string) string {
+	return "limit " + limit + " offset " + offset
+}
+
+func classifyWith(err error, codes map[string]error) (error, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		for _, code := range errorCodes(e) {
+			if kind, ok := codes[code]; ok {
+				return fmt.Errorf("%w: %w", kind, err), true
+			}
+		}
+	}
+	return err, false
+}
+
+func errorCodes(err error) []string {
+	codes := make([]string, 0)
+	if s, ok := err.(interface{ SQLState() string }); ok {
+		codes = append(codes, s.SQLState())
+	}
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() != reflect.Struct {
+		return codes
+	}
+	for _, name := range []string{"Code", "Number", "ExtendedCode"} {
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			codes = append(codes, f.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			codes = append(codes, fmt.Sprintf("%d", f.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			codes = append(codes, fmt.Sprintf("%d", f.Uint()))
+		}
+	}
+	return codes
+}
+
+type Postgres struct {
+}
+
+var postgresTypes = typeNames{
+	integer:   "integer",
+	bigint:    "bigint",
+	float:     "real",
+	double:    "double precision",
+	boolean:   "boolean",
+	varchar:   "varchar",
+	binary:    "bytea",
+	timestamp: "timestamp",
+	uuid:      "uuid",
+}
+
+var postgresCodes = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23502": ErrNotNullViolation,
+}
+
+func (o Postgres) Name() string {
+	return "postgres"
+}
+
+func (o Postgres) Placeholder(index int) string {
+	return fmt.Sprintf("$%d", index)
+}
+
+func (o Postgres) Quote(identifier string) string {
+	return quoteWith(identifier, `"`)
+}
+
+func (o Postgres) ColumnType(field reflect.StructField) string {
+	return mapColumnType(o, postgresTypes, field)
+}
+
+func (o Postgres) Paginate(limit string, offset string) string {
+	return paginate(limit, offset)
+}
+
+func (o Postgres) Classify(err error) error {
+	err, _ = classifyWith(err, postgresCodes)
+	return err
+}
+
+type MySQL struct {
+}
+
+var mysqlTypes = typeNames{
+	integer:   "int",
+	bigint:    "bigint",
+	float:     "float",
+	double:    "double",
+	boolean:   "boolean",
+	varchar:   "varchar",
+	binary:    "longblob",
+	timestamp: "datetime(6)",
+	uuid:      "char(36)",
+}
+
+var mysqlCodes = map[string]error{
+	"1062": ErrUniqueViolation,
+	"1451": ErrForeignKeyViolation,
+	"1452": ErrForeignKeyViolation,
+	"1048": ErrNotNullViolation,
+	"1364": ErrNotNullViolation,
+}
+
+func (o MySQL) Name() string {
+	return "mysql"
+}
+
+func (o MySQL) Placeholder(index int) string {
+	return "?"
+}
+
+func (o MySQL) Quote(identifier string) string {
+	return quoteWith(identifier, "`")
+}
+
+func (o MySQL) ColumnType(field reflect.StructField) string {
+	return mapColumnType(o, mysqlTypes, field)
+}
+
+func (o MySQL) Paginate(limit string, offset string) string {
+	return paginate(limit, offset)
+}
+
+func (o MySQL) Classify(err error) error {
+	err, _ = classifyWith(err, mysqlCodes)
+	return err
+}
+
+type SQLite struct {
+}
+
+var sqliteTypes = typeNames{
+	integer:   "integer",
+	bigint:    "bigint",
+	float:     "real",
+	double:    "double",
+	boolean:   "boolean",
+	varchar:   "varchar",
+	binary:    "blob",
+	timestamp: "timestamp",
+	uuid:      "char(36)",
+}
+
+var sqliteCodes = map[string]error{
+	"2067": ErrUniqueViolation,
+	"1555": ErrUniqueViolation,
+	"787":  ErrForeignKeyViolation,
+	"1299": ErrNotNullViolation,
+}
+
+func (o SQLite) Name() string {
+	return "sqlite"
+}
+
+func (o SQLite) Placeholder(index int) string {
+	return fmt.Sprintf("?%d", index)
+}
+
+func (o SQLite) Quote(identifier string) string {
+	return quoteWith(identifier, `"`)
+}
+
+func (o SQLite) ColumnType(field reflect.StructField) string {
+	return mapColumnType(o, sqliteTypes, field)
+}
+
+func (o SQLite) Paginate(limit string, offset string) string {
+	return paginate(limit, offset)
+}
+
+func (o SQLite) Classify(err error) error {
+	err, ok := classifyWith(err, sqliteCodes)
+	if ok || err == nil {
+		return err
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "UNIQUE constraint failed"):
+		return fmt.Errorf("%w: %w", ErrUniqueViolation, err)
+	case strings.Contains(message, "FOREIGN KEY constraint failed"):
+		return fmt.Errorf("%w: %w", ErrForeignKeyViolation, err)
+	case strings.Contains(message, "NOT NULL constraint failed"):
+		return fmt.Errorf("%w: %w", ErrNotNullViolation, err)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package srm
+
+import (
+	"database/sql/driver"
+	"reflect"
+)
+
+type snapshotKey struct {
+	objectType reflect.Type
+	id         interface{}
+}
+
+// snapshot records the column values of an entity as stored, so a later
+// Update can tell which ones changed.
+func (o *Trx) snapshot(object reflect.Value) {
+	if o.snapshots == nil {
+		o.snapshots = make(map[snapshotKey][]interface{})
+	}
+	args := o.insertArgs(object)
+	for i := range args {
+		args[i] = snapshotValue(args[i])
+	}
+	o.snapshots[snapshotKey{object.Type(), idValue(object.Field(0))}] = args
+}
+
+func (o *Trx) forget(object reflect.Value) {
+	delete(o.snapshots, snapshotKey{object.Type(), idValue(object.Field(0))})
+}
+
+// changedColumns returns the indexes, within columnFields, of the columns
+// differing from the snapshot, and false when the entity is not tracked.
+func (o *Trx) changedColumns(object reflect.Value) ([]int, bool) {
+	previous, ok := o.snapshots[snapshotKey{object.Type(), idValue(object.Field(0))}]
+	if !ok {
+		return nil, false
+	}
+	args := o.insertArgs(object)
+	changed := make([]int, 0)
+	for i := 1; i < len(args); i++ {
+		if !reflect.DeepEqual(previous[i], snapshotValue(args[i])) {
+			changed = append(changed, i)
+		}
+	}
+	return changed, true
+}
+
+// Changes returns the names of the fields of entity modified since it was
+// read or written through this Trx, and false when the Trx does not track
+// it, in which case Update writes every column.
+func (o *Trx) Changes(entity interface{}) ([]string, bool) {
+	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	changed, ok := o.changedColumns(object)
+	if !ok {
+		return nil, false
+	}
+	fields := columnFields(object.Type())
+	names := make([]string, len(changed))
+	for i := range changed {
+		names[i] = fields[changed[i]].Name
+	}
+	return names, true
+}
+
+// snapshotValue copies what an entity shares with the caller, such as
+// pointed values and byte slices, and reduces valuers to their column value.
+func snapshotValue(value interface{}) interface{} {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v := reflect.ValueOf(valuer)
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		if column, err := valuer.Value(); err == nil {
+			value = column
+		}
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case value == nil:
+		return nil
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return snapshotValue(v.Elem().Interface())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return append([]byte(nil), v.Bytes()...)
+	}
+	return value
+}
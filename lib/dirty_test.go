@@ -0,0 +1,71 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type dirtyMaster struct {
+	Id   int64
+	Name string
+}
+
+type dirtyDetail struct {
+	Id      int64
+	Master  dirtyMaster
+	Name    string
+	Note    *string
+	Data    []byte
+	Version int64 `version:"true"`
+}
+
+func TestChangedColumns(t *testing.T) {
+	trx := &Trx{dialect: Postgres{}}
+	note := "a"
+	detail := dirtyDetail{Id: 1, Master: dirtyMaster{Id: 2, Name: "m"}, Name: "d", Note: &note, Data: []byte{1}}
+	object := reflect.ValueOf(&detail).Elem()
+	if _, ok := trx.changedColumns(object); ok {
+		t.Fatal("expected an untracked entity")
+	}
+	trx.snapshot(object)
+	if changed, ok := trx.changedColumns(object); !ok || len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v %v", changed, ok)
+	}
+	detail.Master.Name = "other"
+	note = "b"
+	detail.Data[0] = 2
+	expected := []int{3, 4}
+	if changed, _ := trx.changedColumns(object); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+	detail.Master = dirtyMaster{Id: 3}
+	detail.Name = "e"
+	expected = []int{1, 2, 3, 4}
+	if changed, _ := trx.changedColumns(object); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+}
+
+func TestBuildUpdateSql(t *testing.T) {
+	cases := []struct {
+		dialect  Dialect
+		changed  []int
+		version  int
+		expected string
+	}{
+		{Postgres{}, []int{2}, 0, `update "dirtydetail" set "name" = $1 where "id" = $2`},
+		{Postgres{}, []int{1, 3}, 5, `update "dirtydetail" set "master_id" = $1, "note" = $2 where "id" = $3 and "version" = $4`},
+		{MySQL{}, []int{2, 5}, 5, "update `dirtydetail` set `name` = ?, `version` = ? where `id` = ? and `version` = ?"},
+	}
+	for _, c := range cases {
+		trx := &Trx{dialect: c.dialect}
+		trx.checkMaps()
+		actual := trx.buildUpdateSql(reflect.TypeOf(dirtyDetail{}), "key", c.changed, c.version)
+		if actual != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, actual)
+		}
+		if trx.updateMap["key"] != actual {
+			t.Errorf("expected the statement to be cached under key")
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package srm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+func Query[T any](tx *Trx, conditions string, args ...interface{}) ([]T, error) {
+	return QueryContext[T](context.Background(), tx, conditions, args...)
+}
+
+func QueryContext[T any](ctx context.Context, tx *Trx, conditions string, args ...interface{}) ([]T, error) {
+	var template T
+	if err := checkEntity(reflect.TypeOf(template)); err != nil {
+		return nil, err
+	}
+	r, err := tx.QueryContext(ctx, template, conditions, args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.([]T), nil
+}
+
+func Find[T any](tx *Trx, id interface{}, options ...QueryOption) (*T, error) {
+	return FindContext[T](context.Background(), tx, id, options...)
+}
+
+func FindContext[T any](ctx context.Context, tx *Trx, id interface{}, options ...QueryOption) (*T, error) {
+	var template T
+	if err := checkEntity(reflect.TypeOf(template)); err != nil {
+		return nil, err
+	}
+	r, err := tx.FindContext(ctx, template, id, options...)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	return r.(*T), nil
+}
+
+func checkEntity(objectType reflect.Type) error {
+	if objectType == nil || !IsEntity(objectType) {
+		return fmt.Errorf("%v is not an entity", objectType)
+	}
+	return nil
+}
+
+func Count[T any](tx *Trx, conditions string, args ...interface{}) (int64, error) {
+	return CountContext[T](context.Background(), tx, conditions, args...)
+}
+
+func CountContext[T any](ctx context.Context, tx *Trx, conditions string, args ...interface{}) (int64, error) {
+	var template T
+	if err := checkEntity(reflect.TypeOf(template)); err != nil {
+		return 0, err
+	}
+	return tx.CountContext(ctx, template, conditions, args...)
+}
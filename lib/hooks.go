@@ -0,0 +1,98 @@
+package srm
+
+import (
+	"reflect"
+)
+
+// Entities implementing any of the following interfaces, with pointer
+// receivers, get them called around persistence. An error returned by a
+// Before hook aborts the operation before any SQL is sent; one returned by an
+// After hook is returned by the operation, leaving the transaction to be
+// rolled back.
+
+// BeforePersister is called by Persist and PersistAll before ids, versions
+// and auto times are assigned.
+type BeforePersister interface {
+	BeforePersist(tx *Trx) error
+}
+
+// AfterPersister is called by Persist and PersistAll once the row is inserted.
+type AfterPersister interface {
+	AfterPersist(tx *Trx) error
+}
+
+// BeforeUpdater is called by Update before changes are detected.
+type BeforeUpdater interface {
+	BeforeUpdate(tx *Trx) error
+}
+
+// BeforeDeleter is called by Delete and HardDelete.
+type BeforeDeleter interface {
+	BeforeDelete(tx *Trx) error
+}
+
+// AfterLoader is called on every entity read by a query, fetched relations
+// included and before the entity holding them, once its fields and eager
+// collections are set and the result set is closed, so it may query through
+// tx.
+type AfterLoader interface {
+	AfterLoad(tx *Trx) error
+}
+
+func (o *Trx) beforePersist(object reflect.Value) error {
+	if hook, ok := object.Addr().Interface().(BeforePersister); ok {
+		return hook.BeforePersist(o)
+	}
+	return nil
+}
+
+func (o *Trx) afterPersist(object reflect.Value) error {
+	if hook, ok := object.Addr().Interface().(AfterPersister); ok {
+		return hook.AfterPersist(o)
+	}
+	return nil
+}
+
+func (o *Trx) beforeUpdate(object reflect.Value) error {
+	if hook, ok := object.Addr().Interface().(BeforeUpdater); ok {
+		return hook.BeforeUpdate(o)
+	}
+	return nil
+}
+
+func (o *Trx) beforeDelete(object reflect.Value) error {
+	if hook, ok := object.Addr().Interface().(BeforeDeleter); ok {
+		return hook.BeforeDelete(o)
+	}
+	return nil
+}
+
+func (o *Trx) afterLoad(object reflect.Value) error {
+	if hook, ok := object.Addr().Interface().(AfterLoader); ok {
+		return hook.AfterLoad(o)
+	}
+	return nil
+}
+
+// afterLoadDeep calls afterLoad on the relations of object fetched after plan,
+// then on object, where the query left them.
+func (o *Trx) afterLoadDeep(object reflect.Value, plan *fetchPlan) error {
+	columns := columnFields(object.Type())
+	for i := 1; i < len(columns); i++ {
+		field := columns[i]
+		if !IsRelation(field.Type) || !plan.fetches(field.Name) {
+			continue
+		}
+		of := object.FieldByIndex(field.Index)
+		if of.Kind() == reflect.Ptr {
+			if of.IsNil() {
+				continue
+			}
+			of = of.Elem()
+		}
+		if err := o.afterLoadDeep(of, plan.child(field.Name)); err != nil {
+			return err
+		}
+	}
+	return o.afterLoad(object)
+}
@@ -0,0 +1,55 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type hookedMaster struct {
+	Id     int64
+	loaded *[]string
+}
+
+func (o *hookedMaster) AfterLoad(tx *Trx) error {
+	*o.loaded = append(*o.loaded, "master")
+	return nil
+}
+
+type hookedDetail struct {
+	Id     int64
+	Master hookedMaster
+	Other  *hookedMaster
+	loaded *[]string
+}
+
+func (o *hookedDetail) AfterLoad(tx *Trx) error {
+	*o.loaded = append(*o.loaded, "detail")
+	return nil
+}
+
+func TestAfterLoadDeep(t *testing.T) {
+	cases := []struct {
+		options  []interface{}
+		other    bool
+		expected []string
+	}{
+		{nil, true, []string{"master", "master", "detail"}},
+		{nil, false, []string{"master", "detail"}},
+		{[]interface{}{MaxDepth(0)}, true, []string{"detail"}},
+		{[]interface{}{Fetch("Other")}, true, []string{"master", "detail"}},
+	}
+	for _, c := range cases {
+		loaded := make([]string, 0)
+		detail := hookedDetail{Master: hookedMaster{loaded: &loaded}, loaded: &loaded}
+		if c.other {
+			detail.Other = &hookedMaster{loaded: &loaded}
+		}
+		_, options := splitArgs(c.options)
+		if err := (&Trx{}).afterLoadDeep(reflect.ValueOf(&detail).Elem(), options.plan); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, c.expected) {
+			t.Errorf("%v: expected %v, got %v", c.options, c.expected, loaded)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package srm
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// IsIdType reports whether idType can identify an entity: int64, string or a
+// 16 byte array such as a UUID.
+func IsIdType(idType reflect.Type) bool {
+	switch idType.Kind() {
+	case reflect.Int64, reflect.String:
+		return true
+	}
+	return IsUUIDType(idType)
+}
+
+func IsUUIDType(idType reflect.Type) bool {
+	return idType.Kind() == reflect.Array && idType.Len() == 16 && idType.Elem().Kind() == reflect.Uint8
+}
+
+// idValue returns the value bound for an id. UUID arrays that are not
+// driver.Valuers are bound in their textual form, which every dialect maps
+// them to.
+func idValue(id reflect.Value) interface{} {
+	if IsUUIDType(id.Type()) && !id.Type().Implements(valuerType) {
+		var u [16]byte
+		reflect.Copy(reflect.ValueOf(&u).Elem(), id)
+		return FormatUUID(u)
+	}
+	return id.Interface()
+}
+
+// convertId turns a raw column value into idType.
+func convertId(src interface{}, idType reflect.Type) (reflect.Value, error) {
+	id := reflect.New(idType)
+	if reflect.PtrTo(idType).Implements(scannerType) {
+		err := id.Interface().(sql.Scanner).Scan(src)
+		return id.Elem(), err
+	}
+	text := ""
+	switch v := src.(type) {
+	case int64:
+		if idType.Kind() == reflect.Int64 {
+			id.Elem().SetInt(v)
+			return id.Elem(), nil
+		}
+		text = strconv.FormatInt(v, 10)
+	case []byte:
+		if IsUUIDType(idType) && len(v) == 16 {
+			reflect.Copy(id.Elem(), reflect.ValueOf(v))
+			return id.Elem(), nil
+		}
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return id.Elem(), fmt.Errorf("cannot convert %T to an id of type %v", src, idType)
+	}
+	switch {
+	case idType.Kind() == reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, 64)
+		id.Elem().SetInt(i)
+		return id.Elem(), err
+	case idType.Kind() == reflect.String:
+		id.Elem().SetString(text)
+	default:
+		u, err := ParseUUID(text)
+		if err != nil {
+			return id.Elem(), err
+		}
+		reflect.Copy(id.Elem(), reflect.ValueOf(u[:]))
+	}
+	return id.Elem(), nil
+}
+
+// idBuffer scans a nullable id column of any supported type.
+type idBuffer struct {
+	idType reflect.Type
+	value  reflect.Value
+	valid  bool
+}
+
+func newIdBuffer(idType reflect.Type) *idBuffer {
+	return &idBuffer{idType: idType}
+}
+
+func (o *idBuffer) Scan(src interface{}) error {
+	o.valid = src != nil
+	if !o.valid {
+		return nil
+	}
+	var err error
+	o.value, err = convertId(src, o.idType)
+	return err
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() [16]byte {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+func FormatUUID(u [16]byte) string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+func ParseUUID(s string) ([16]byte, error) {
+	var u [16]byte
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 {
+		return u, fmt.Errorf("invalid uuid %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
@@ -0,0 +1,356 @@
+package srm
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdGenerator assigns the id of an entity persisted with a zero id. The
+// returned value is an int64, a string or a [16]byte and is converted to the
+// type of the Id field.
+type IdGenerator interface {
+	NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error)
+}
+
+var idGeneratorMux sync.Mutex
+
+// defaultHiLo generates int64 ids unless told otherwise, its hi values coming
+// from the tkt sequence of the table so that they follow the ids it issued.
+var defaultHiLo = NewHiLo(TktSequences{}, 100)
+
+var idGenerators = map[string]IdGenerator{
+	"tkt":       TktSequences{},
+	"identity":  Identity{},
+	"sequence":  Sequence{},
+	"hilo":      defaultHiLo,
+	"uuid":      RandomUUID{},
+	"uuidv7":    UUIDv7{},
+	"snowflake": NewSnowflake(0),
+}
+
+var entityIdGenerators = map[reflect.Type]IdGenerator{}
+
+// RegisterIdGenerator makes generator available to the id tag, as in
+// `id:"name"` on the Id field.
+func RegisterIdGenerator(name string, generator IdGenerator) {
+	idGeneratorMux.Lock()
+	defer idGeneratorMux.Unlock()
+	idGenerators[name] = generator
+}
+
+// UseIdGenerator sets the generator of the template's entity, overriding its
+// id tag.
+func UseIdGenerator(template interface{}, generator IdGenerator) {
+	idGeneratorMux.Lock()
+	defer idGeneratorMux.Unlock()
+	entityIdGenerators[reflect.TypeOf(template)] = generator
+}
+
+// IdGeneratorFor resolves the generator of objectType: the one set with
+// UseIdGenerator, the one named by the id tag, or by default hi/lo over tkt
+// sequences for int64 ids and random UUIDs otherwise.
+func IdGeneratorFor(objectType reflect.Type) (IdGenerator, error) {
+	idGeneratorMux.Lock()
+	defer idGeneratorMux.Unlock()
+	if generator, ok := entityIdGenerators[objectType]; ok {
+		return generator, nil
+	}
+	idField := objectType.Field(0)
+	if name, ok := idField.Tag.Lookup("id"); ok {
+		generator, ok := idGenerators[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: no id generator registered as %q", objectType.Name(), name)
+		}
+		return generator, nil
+	}
+	if idField.Type.Kind() == reflect.Int64 {
+		return defaultHiLo, nil
+	}
+	return RandomUUID{}, nil
+}
+
+func isIdentity(generator IdGenerator) bool {
+	_, ok := generator.(Identity)
+	return ok
+}
+
+func assignId(idField reflect.Value, id interface{}) error {
+	switch v := id.(type) {
+	case int64:
+		if idField.Kind() == reflect.Int64 {
+			idField.SetInt(v)
+			return nil
+		}
+	case string:
+		if idField.Kind() == reflect.String {
+			idField.SetString(v)
+			return nil
+		}
+	case [16]byte:
+		if idField.Kind() == reflect.String {
+			idField.SetString(FormatUUID(v))
+			return nil
+		}
+		if IsUUIDType(idField.Type()) {
+			reflect.Copy(idField, reflect.ValueOf(v[:]))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot assign generated id %v to an id of type %v", id, idField.Type())
+}
+
+// TktSequences draws ids from the tkt sequences, one per table. tkt hands out
+// a single id per call, so int64 ids default to HiLo over it instead.
+type TktSequences struct {
+}
+
+func (o TktSequences) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	var id int64
+	err := catch(func() {
+		id = trx.sequences.Next(FqTableName(objectType))
+	})
+	return id, err
+}
+
+// Identity leaves the id to an identity, serial or auto increment column and
+// reads it back after the insert.
+type Identity struct {
+}
+
+func (o Identity) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	return nil, fmt.Errorf("%s: identity ids are assigned by the database", objectType.Name())
+}
+
+// IdentityDialect is implemented by dialects supporting Identity ids.
+type IdentityDialect interface {
+	// IdentityType returns the column type for an identity id otherwise
+	// declared as columnType.
+	IdentityType(columnType string) string
+	// IdentityClause is appended to the column type in create table.
+	IdentityClause() string
+	// Returning returns the clause making an insert return column, or "" when
+	// the id is read through sql.Result.LastInsertId.
+	Returning(column string) string
+}
+
+// Sequence draws ids from a native database sequence, <table>_seq unless Name
+// is set.
+type Sequence struct {
+	Name string
+}
+
+// SequenceDialect is implemented by dialects supporting native sequences.
+type SequenceDialect interface {
+	NextValue(sequence string) string
+	NextValues(sequence string, count int) string
+}
+
+func (o Sequence) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	dialect, ok := trx.dialect.(SequenceDialect)
+	if !ok {
+		return nil, fmt.Errorf("dialect %s has no sequences", trx.dialect.Name())
+	}
+	var id int64
+	err := trx.tx.QueryRowContext(ctx, dialect.NextValue(o.sequenceName(objectType))).Scan(&id)
+	return id, trx.dialect.Classify(err)
+}
+
+func (o Sequence) sequenceName(objectType reflect.Type) string {
+	if o.Name == "" {
+		return FqTableName(objectType) + "_seq"
+	}
+	return o.Name
+}
+
+// HiLo hands out blocks of BlockSize ids per value drawn from Hi, so only one
+// id in BlockSize costs a round trip. Hi must produce positive int64s. Blocks
+// are kept per database, so that one is never spent on another.
+type HiLo struct {
+	Hi        IdGenerator
+	BlockSize int64
+	mux       sync.Mutex
+	blocks    map[hiloKey]*hiloBlock
+}
+
+type hiloKey struct {
+	db         *sql.DB
+	objectType reflect.Type
+}
+
+type hiloBlock struct {
+	next int64
+	last int64
+}
+
+func NewHiLo(hi IdGenerator, blockSize int64) *HiLo {
+	return &HiLo{Hi: hi, BlockSize: blockSize}
+}
+
+func (o *HiLo) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	ids, err := o.NextIds(ctx, trx, objectType, 1)
+	if err != nil {
+		return nil, err
+	}
+	return ids[0], nil
+}
+
+// NextIds takes what is left of the current block and draws the hi values of
+// the blocks still needed at once, in a single round trip when Hi is a
+// BlockIdGenerator.
+func (o *HiLo) NextIds(ctx context.Context, trx *Trx, objectType reflect.Type, count int) ([]interface{}, error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.blocks == nil {
+		o.blocks = make(map[hiloKey]*hiloBlock)
+	}
+	key := hiloKey{objectType: objectType}
+	if trx != nil {
+		key.db = trx.db
+	}
+	ids := make([]interface{}, 0, count)
+	block, ok := o.blocks[key]
+	for ok && len(ids) < count && block.next <= block.last {
+		ids = append(ids, block.next)
+		block.next++
+	}
+	missing := int64(count - len(ids))
+	if missing == 0 {
+		return ids, nil
+	}
+	his, err := nextIds(ctx, trx, o.Hi, objectType, int((missing+o.BlockSize-1)/o.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	for i := range his {
+		h, ok := his[i].(int64)
+		if !ok {
+			return nil, fmt.Errorf("hi/lo needs int64 hi values, got %T", his[i])
+		}
+		block = &hiloBlock{next: (h-1)*o.BlockSize + 1, last: h * o.BlockSize}
+		for len(ids) < count && block.next <= block.last {
+			ids = append(ids, block.next)
+			block.next++
+		}
+	}
+	o.blocks[key] = block
+	return ids, nil
+}
+
+// RandomUUID generates version 4 UUIDs.
+type RandomUUID struct {
+}
+
+func (o RandomUUID) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	return NewUUID(), nil
+}
+
+// UUIDv7 generates time ordered version 7 UUIDs, which index better than
+// random ones.
+type UUIDv7 struct {
+}
+
+func (o UUIDv7) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return nil, err
+	}
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(u[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	return u, nil
+}
+
+// SnowflakeEpoch is the origin of snowflake timestamps.
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates time ordered int64 ids made of 41 bits of milliseconds
+// since SnowflakeEpoch, 10 bits of node and 12 bits of sequence.
+type Snowflake struct {
+	Node     int64
+	mux      sync.Mutex
+	last     int64
+	sequence int64
+}
+
+func NewSnowflake(node int64) *Snowflake {
+	return &Snowflake{Node: node}
+}
+
+func (o *Snowflake) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	if o.Node < 0 || o.Node > 1023 {
+		return nil, fmt.Errorf("snowflake node %d out of range", o.Node)
+	}
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	ms := time.Since(SnowflakeEpoch).Milliseconds()
+	if ms < o.last {
+		ms = o.last
+	}
+	if ms == o.last {
+		o.sequence = (o.sequence + 1) & 0xfff
+		if o.sequence == 0 {
+			for ms <= o.last {
+				ms = time.Since(SnowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		o.sequence = 0
+	}
+	o.last = ms
+	return ms<<22 | o.Node<<12 | o.sequence, nil
+}
+
+func (o Postgres) IdentityType(columnType string) string {
+	return columnType
+}
+
+func (o Postgres) IdentityClause() string {
+	return " generated by default as identity"
+}
+
+func (o Postgres) Returning(column string) string {
+	return " returning " + column
+}
+
+func (o Postgres) NextValue(sequence string) string {
+	return "select nextval('" + strings.ReplaceAll(sequence, "'", "''") + "')"
+}
+
+func (o Postgres) NextValues(sequence string, count int) string {
+	return fmt.Sprintf("select nextval('%s') from generate_series(1, %d)", strings.ReplaceAll(sequence, "'", "''"), count)
+}
+
+func (o MySQL) IdentityType(columnType string) string {
+	return columnType
+}
+
+func (o MySQL) IdentityClause() string {
+	return " auto_increment"
+}
+
+func (o MySQL) Returning(column string) string {
+	return ""
+}
+
+// SQLite assigns ids only to columns declared exactly as integer primary key,
+// which alias the rowid.
+func (o SQLite) IdentityType(columnType string) string {
+	return "integer"
+}
+
+func (o SQLite) IdentityClause() string {
+	return ""
+}
+
+func (o SQLite) Returning(column string) string {
+	return ""
+}
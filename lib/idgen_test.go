@@ -0,0 +1,75 @@
+package srm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+type countingHi struct {
+	calls int
+	last  int64
+}
+
+func (o *countingHi) NextId(ctx context.Context, trx *Trx, objectType reflect.Type) (interface{}, error) {
+	o.calls++
+	o.last++
+	return o.last, nil
+}
+
+type hiloEntity struct {
+	Id int64
+}
+
+func TestHiLo(t *testing.T) {
+	hi := &countingHi{}
+	generator := NewHiLo(hi, 100)
+	objectType := reflect.TypeOf(hiloEntity{})
+	trx := &Trx{db: &sql.DB{}}
+	first, err := generator.NextId(context.Background(), trx, objectType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := generator.NextIds(context.Background(), trx, objectType, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != int64(1) || len(ids) != 250 || ids[0] != int64(2) || ids[249] != int64(251) || hi.calls != 3 {
+		t.Fatalf("got %v, %d ids from %v to %v, %d hi calls", first, len(ids), ids[0], ids[len(ids)-1], hi.calls)
+	}
+	next, _ := generator.NextId(context.Background(), trx, objectType)
+	if next != int64(252) || hi.calls != 3 {
+		t.Errorf("expected 252 from the current block, got %v after %d hi calls", next, hi.calls)
+	}
+	other, _ := generator.NextId(context.Background(), &Trx{db: &sql.DB{}}, objectType)
+	if other != int64(301) || hi.calls != 4 {
+		t.Errorf("expected 301 from a block of its own database, got %v after %d hi calls", other, hi.calls)
+	}
+}
+
+func TestDefaultIdGenerator(t *testing.T) {
+	type uuidEntity struct {
+		Id string
+	}
+	type tktEntity struct {
+		Id int64 `id:"tkt"`
+	}
+	cases := []struct {
+		template interface{}
+		expected IdGenerator
+	}{
+		{hiloEntity{}, defaultHiLo},
+		{uuidEntity{}, RandomUUID{}},
+		{tktEntity{}, TktSequences{}},
+	}
+	for _, c := range cases {
+		generator, err := IdGeneratorFor(reflect.TypeOf(c.template))
+		if err != nil || generator != c.expected {
+			t.Errorf("%T: expected %T, got %T %v", c.template, c.expected, generator, err)
+		}
+	}
+	if _, ok := IdGenerator(defaultHiLo).(BlockIdGenerator); !ok {
+		t.Errorf("expected the default int64 generator to reserve ids in blocks")
+	}
+}
@@ -1,109 +1,170 @@
 package srm
 
 import (
+	"context"
 	"github.com/gabrielmorenobrc/go-tkt/lib"
+	"database/sql"
 	"reflect"
 	"bytes"
+	"sync"
 	"time"
 )
 
+// PoolConfig sizes the connection pool. Lifetimes are in seconds, zero
+// keeping connections indefinitely.
+type PoolConfig struct {
+	MaxOpenConns    int `json:"maxOpenConns"`
+	MaxIdleConns    int `json:"maxIdleConns"`
+	ConnMaxLifetime int `json:"connMaxLifetime"`
+	ConnMaxIdleTime int `json:"connMaxIdleTime"`
+}
+
 type Mgr struct {
 	DatabaseConfig tkt.DatabaseConfig
+	PoolConfig     PoolConfig
+	Dialect        Dialect
+	db             *sql.DB
+	sequences      *tkt.Sequences
+	mux            sync.Mutex
+}
+
+func (o *Mgr) StartTransaction() (*Trx, error) {
+	return o.BeginTx(context.Background(), nil)
 }
 
-func (o *Mgr) StartTransaction() *Trx {
+func (o *Mgr) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Trx, error) {
 	transaction := Trx{}
-	db := tkt.OpenDB(o.DatabaseConfig)
-	tx, err := db.Begin()
-	tkt.CheckErr(err)
-	sequences := tkt.NewSequences(o.DatabaseConfig)
-	transaction.Init(db, tx, sequences)
-	return &transaction
+	db, err := o.checkDB()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	transaction.Init(db, tx, o.sequences, o.Dialect)
+	return &transaction, nil
+}
+
+func (o *Mgr) Stats() sql.DBStats {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.db == nil {
+		return sql.DBStats{}
+	}
+	return o.db.Stats()
+}
+
+func (o *Mgr) Close() error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.db == nil {
+		return nil
+	}
+	err := o.db.Close()
+	o.db = nil
+	o.sequences = nil
+	return err
 }
 
-func (o *Mgr) CreateTables(templates []interface{}) {
-	trx := o.StartTransaction()
-	defer trx.RollbackOnPanic()
+func (o *Mgr) checkDB() (*sql.DB, error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.db != nil {
+		return o.db, nil
+	}
+	if o.Dialect == nil {
+		dialect, err := DialectFor(o.DatabaseConfig.DatabaseDriver)
+		if err != nil {
+			return nil, err
+		}
+		o.Dialect = dialect
+	}
+	var db *sql.DB
+	var sequences *tkt.Sequences
+	err := catch(func() {
+		db = tkt.OpenDB(o.DatabaseConfig)
+		sequences = tkt.NewSequences(o.DatabaseConfig)
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(o.PoolConfig.MaxOpenConns)
+	if o.PoolConfig.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(o.PoolConfig.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(time.Duration(o.PoolConfig.ConnMaxLifetime) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(o.PoolConfig.ConnMaxIdleTime) * time.Second)
+	o.db = db
+	o.sequences = sequences
+	return db, nil
+}
+
+func (o *Mgr) CreateTables(templates []interface{}) error {
+	trx, err := o.StartTransaction()
+	if err != nil {
+		return err
+	}
 	for i := range templates {
 		t := templates[i]
-		o.createTable(trx, t)
+		if err := o.createTable(trx, t); err != nil {
+			trx.Rollback()
+			return err
+		}
 	}
-	trx.Commit()
+	return trx.Commit()
 }
 
-func (o *Mgr) createTable(trx *Trx, template interface{}) {
+func (o *Mgr) createTable(trx *Trx, template interface{}) error {
 	objectType := reflect.TypeOf(template)
 
-	r, err := trx.db.Query("select * from " + objectType.Name() + " where 1 = 2")
+	r, err := trx.db.Query("select * from " + trx.table(objectType) + " where 1 = 2")
 	if err == nil {
 		r.Close()
 		tkt.Logger("srm").Printf("%s already exists", objectType.Name())
-		return
+		return nil
 	}
 
+	sql := o.buildCreateTableSql(trx.dialect, objectType)
+	tkt.Logger("srm").Println(sql)
+	_, err = trx.tx.Exec(sql)
+	return err
+}
+
+func (o *Mgr) buildCreateTableSql(dialect Dialect, objectType reflect.Type) string {
+	specs := buildColumnSpecs(dialect, objectType)
 	buffer := bytes.Buffer{}
 	buffer.WriteString("create table ")
-	idField, _ := objectType.FieldByName("Id")
-	schema, ok := idField.Tag.Lookup("schema")
-	if ok {
-		buffer.WriteString(schema)
-		buffer.WriteString(".")
-	}
-	buffer.WriteString(objectType.Name())
+	buffer.WriteString(quoteFq(dialect, FqTableName(objectType)))
 	buffer.WriteString("(\r\n")
-	for i := 0; i < objectType.NumField(); i++ {
+	for i := range specs {
 		if i > 0 {
 			buffer.WriteString(",\r\n")
 		}
-		f := objectType.Field(i)
-		buffer.WriteString(f.Name)
-		if f.Type == reflect.TypeOf(time.Now()) {
-			temporal := f.Tag.Get("temporal")
-			buffer.WriteString(" ")
-			buffer.WriteString(temporal)
-		} else if f.Type.Kind() == reflect.Struct {
-			buffer.WriteString("_id bigint")
-		} else if f.Type == reflect.TypeOf(0) {
-			buffer.WriteString(" int")
-		} else if f.Type == reflect.TypeOf(int64(0)) {
-			buffer.WriteString(" bigint")
-		} else if f.Type == reflect.TypeOf([]uint8{}) {
-			precision, _ := f.Tag.Lookup("precision")
-			buffer.WriteString(" numeric(")
-			buffer.WriteString(precision)
-			buffer.WriteString(")")
-		} else if f.Type == reflect.TypeOf(float32(0.0)) {
-			buffer.WriteString(" float")
-		} else if f.Type == reflect.TypeOf(float64(0.0)) {
-			buffer.WriteString(" double precision")
-		} else if f.Type == reflect.TypeOf("") {
-			len, ok := f.Tag.Lookup("len")
-			if !ok {
-				len = "255"
-			}
-			buffer.WriteString(" varchar(")
-			buffer.WriteString(len)
-			buffer.WriteString(")")
-		} else if f.Type == reflect.TypeOf(make([]byte, 0)) {
-			buffer.WriteString(" blob")
+		spec := specs[i]
+		buffer.WriteString(dialect.Quote(spec.name))
+		buffer.WriteString(" ")
+		buffer.WriteString(spec.sqlType)
+		if spec.identity {
+			buffer.WriteString(dialect.(IdentityDialect).IdentityClause())
 		}
-		buffer.WriteString(" not null")
+		buffer.WriteString(nullability(spec.nullable))
 	}
-	buffer.WriteString(",\r\nprimary key(id)")
-	for i := 0; i < objectType.NumField(); i++ {
-		f := objectType.Field(i)
-		fieldType := f.Type
-		if IsEntity(fieldType) {
+	buffer.WriteString(",\r\nprimary key(")
+	buffer.WriteString(dialect.Quote(specs[0].name))
+	buffer.WriteString(")")
+	for i := range specs {
+		spec := specs[i]
+		if spec.refType != nil {
 			buffer.WriteString(",\r\n foreign key(")
-			buffer.WriteString(f.Name)
-			buffer.WriteString("_id) references ")
-			buffer.WriteString(FqTableName(f.Type))
-			buffer.WriteString("(id)")
+			buffer.WriteString(dialect.Quote(spec.name))
+			buffer.WriteString(") references ")
+			buffer.WriteString(quoteFq(dialect, FqTableName(spec.refType)))
+			buffer.WriteString("(")
+			buffer.WriteString(dialect.Quote(spec.refColumn))
+			buffer.WriteString(")")
 		}
 	}
-	buffer.WriteString(");")
-	sql := buffer.String()
-	tkt.Logger("srm").Println(sql)
-	_, err = trx.tx.Exec(sql)
-	tkt.CheckErr(err)
+	buffer.WriteString(")")
+	return buffer.String()
 }
@@ -0,0 +1,287 @@
+package srm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"github.com/gabrielmorenobrc/go-tkt/lib"
+)
+
+type Migration struct {
+	Version    string
+	Statements []string
+	// Skipped describes changes the dialect cannot express, which have to be
+	// applied by hand.
+	Skipped []string
+	// Drops remove columns no longer mapped, losing their data. Migrate leaves
+	// them out; they run from a file written by WriteMigration once reviewed.
+	Drops []string
+}
+
+func (o *Migration) Empty() bool {
+	return len(o.Statements) == 0 && len(o.Skipped) == 0 && len(o.Drops) == 0
+}
+
+func (o *Migration) Sql() string {
+	buffer := strings.Builder{}
+	for i := range o.Skipped {
+		buffer.WriteString("-- ")
+		buffer.WriteString(o.Skipped[i])
+		buffer.WriteString("\n")
+	}
+	for i := range o.Statements {
+		buffer.WriteString(o.Statements[i])
+		buffer.WriteString(";\n")
+	}
+	for i := range o.Drops {
+		buffer.WriteString(o.Drops[i])
+		buffer.WriteString(";\n")
+	}
+	return buffer.String()
+}
+
+func (o *Migration) add(statement string, description string) {
+	if statement == "" {
+		o.Skipped = append(o.Skipped, description)
+	} else {
+		o.Statements = append(o.Statements, statement)
+	}
+}
+
+type migrationRecord struct {
+	Version string `len:"64"`
+	Applied time.Time
+}
+
+const migrationTable = "srm_migration"
+
+// PlanMigration diffs the templates against the live catalog without
+// changing anything.
+func (o *Mgr) PlanMigration(templates []interface{}) (*Migration, error) {
+	trx, err := o.StartTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer trx.Rollback()
+	return o.planMigration(context.Background(), trx, templates)
+}
+
+// Migrate plans and applies a migration in one go, recording its version.
+// Column drops are not applied but returned in Drops.
+func (o *Mgr) Migrate(templates []interface{}) (*Migration, error) {
+	ctx := context.Background()
+	trx, err := o.StartTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer trx.Rollback()
+	if _, err := o.appliedMigrations(ctx, trx); err != nil {
+		return nil, err
+	}
+	m, err := o.planMigration(ctx, trx, templates)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Skipped) > 0 {
+		return m, fmt.Errorf("migration %s needs manual changes: %s", m.Version, strings.Join(m.Skipped, "; "))
+	}
+	if len(m.Statements) == 0 {
+		return m, trx.Commit()
+	}
+	if err := o.applyMigration(ctx, trx, m.Version, m.Statements); err != nil {
+		return m, err
+	}
+	return m, trx.Commit()
+}
+
+// WriteMigration stores the planned migration as <version>.sql in dir so it
+// can be reviewed before ApplyMigrations runs it. It returns "" when the
+// schema is up to date.
+func (o *Mgr) WriteMigration(dir string, templates []interface{}) (string, error) {
+	m, err := o.PlanMigration(templates)
+	if err != nil || m.Empty() {
+		return "", err
+	}
+	path := filepath.Join(dir, m.Version+".sql")
+	return path, os.WriteFile(path, []byte(m.Sql()), 0644)
+}
+
+// ApplyMigrations runs, in name order, every .sql file in dir whose version
+// is not yet recorded, each one in its own transaction.
+func (o *Mgr) ApplyMigrations(dir string) ([]string, error) {
+	ctx := context.Background()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	versions := make([]string, 0)
+	for i := range paths {
+		version := strings.TrimSuffix(filepath.Base(paths[i]), ".sql")
+		ok, err := o.applyMigrationFile(ctx, version, paths[i])
+		if err != nil {
+			return versions, err
+		}
+		if ok {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (o *Mgr) applyMigrationFile(ctx context.Context, version string, path string) (bool, error) {
+	trx, err := o.StartTransaction()
+	if err != nil {
+		return false, err
+	}
+	defer trx.Rollback()
+	applied, err := o.appliedMigrations(ctx, trx)
+	if err != nil || applied[version] {
+		return false, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	statements, err := splitStatements(file)
+	if err != nil {
+		return false, err
+	}
+	if err := o.applyMigration(ctx, trx, version, statements); err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	return true, trx.Commit()
+}
+
+func (o *Mgr) applyMigration(ctx context.Context, trx *Trx, version string, statements []string) error {
+	for i := range statements {
+		if _, err := trx.ExecContext(ctx, statements[i]); err != nil {
+			return err
+		}
+	}
+	sql := "insert into " + trx.dialect.Quote(migrationTable) + "(" + trx.dialect.Quote("version") + ", " + trx.dialect.Quote("applied") +
+		") values(" + trx.dialect.Placeholder(1) + ", " + trx.dialect.Placeholder(2) + ")"
+	_, err := trx.ExecContext(ctx, sql, version, time.Now().UTC())
+	return err
+}
+
+func (o *Mgr) appliedMigrations(ctx context.Context, trx *Trx) (map[string]bool, error) {
+	dialect := trx.dialect
+	recordType := reflect.TypeOf(migrationRecord{})
+	versionField, _ := recordType.FieldByName("Version")
+	appliedField, _ := recordType.FieldByName("Applied")
+	sql := "create table if not exists " + dialect.Quote(migrationTable) + "(" +
+		dialect.Quote("version") + " " + dialect.ColumnType(versionField) + " not null, " +
+		dialect.Quote("applied") + " " + dialect.ColumnType(appliedField) + " not null, " +
+		"primary key(" + dialect.Quote("version") + "))"
+	if _, err := trx.ExecContext(ctx, sql); err != nil {
+		return nil, err
+	}
+	r, err := trx.tx.QueryContext(ctx, "select "+dialect.Quote("version")+" from "+dialect.Quote(migrationTable))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	applied := make(map[string]bool)
+	for r.Next() {
+		var version string
+		if err := r.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, r.Err()
+}
+
+func (o *Mgr) planMigration(ctx context.Context, trx *Trx, templates []interface{}) (*Migration, error) {
+	dialect := trx.dialect
+	schemaDialect, ok := dialect.(SchemaDialect)
+	if !ok {
+		return nil, fmt.Errorf("dialect %s cannot inspect the catalog", dialect.Name())
+	}
+	version := strings.ReplaceAll(time.Now().UTC().Format("20060102150405.000"), ".", "")
+	m := Migration{Version: version, Statements: make([]string, 0), Skipped: make([]string, 0), Drops: make([]string, 0)}
+	for i := range templates {
+		objectType := reflect.TypeOf(templates[i])
+		issues, err := o.inspectTable(ctx, trx, schemaDialect, objectType)
+		if err != nil {
+			return nil, err
+		}
+		o.diffTable(&m, dialect, schemaDialect, objectType, issues)
+	}
+	if !m.Empty() {
+		tkt.Logger("srm").Println(m.Sql())
+	}
+	return &m, nil
+}
+
+func (o *Mgr) diffTable(m *Migration, dialect Dialect, schemaDialect SchemaDialect, objectType reflect.Type, issues []SchemaIssue) {
+	table := quoteFq(dialect, FqTableName(objectType))
+	altered := make(map[string]bool)
+	for i := range issues {
+		issue := issues[i]
+		description := issue.String()
+		switch issue.Kind {
+		case MissingTable:
+			m.add(o.buildCreateTableSql(dialect, objectType), description)
+		case ExtraForeignKey:
+			m.add(schemaDialect.DropForeignKey(table, dialect.Quote(issue.key.Name)), description)
+		case MissingColumn:
+			spec := issue.spec
+			m.add(schemaDialect.AddColumn(table, dialect.Quote(spec.name), spec.sqlType, spec.nullable), description)
+		case TypeMismatch, NullabilityMismatch:
+			spec := issue.spec
+			if altered[spec.name] {
+				continue
+			}
+			altered[spec.name] = true
+			statements := schemaDialect.AlterColumn(table, dialect.Quote(spec.name), spec.sqlType, spec.nullable)
+			if len(statements) == 0 {
+				m.add("", description)
+			}
+			for j := range statements {
+				m.add(statements[j], description)
+			}
+		case ExtraColumn:
+			if statement := schemaDialect.DropColumn(table, dialect.Quote(issue.Column)); statement != "" {
+				m.Drops = append(m.Drops, statement)
+			} else {
+				m.add("", description)
+			}
+		case MissingForeignKey:
+			spec := issue.spec
+			m.add(schemaDialect.AddForeignKey(table, dialect.Quote(spec.name), quoteFq(dialect, FqTableName(spec.refType)), dialect.Quote(spec.refColumn)), description)
+		}
+	}
+}
+
+func splitStatements(file *os.File) ([]string, error) {
+	statements := make([]string, 0)
+	statement := strings.Builder{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		if statement.Len() > 0 {
+			statement.WriteString("\n")
+		}
+		statement.WriteString(line)
+		if strings.HasSuffix(line, ";") {
+			statements = append(statements, strings.TrimSuffix(statement.String(), ";"))
+			statement.Reset()
+		}
+	}
+	if statement.Len() > 0 {
+		statements = append(statements, statement.String())
+	}
+	return statements, scanner.Err()
+}
@@ -0,0 +1,88 @@
+package srm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Named binds the :name placeholders of the conditions from values, a map
+// with string keys or a struct, or pointer to one, whose fields are matched
+// ignoring case. Their values are bound after the positional arguments, a
+// name used twice being bound twice, so dialects with unnumbered placeholders,
+// such as MySQL, accept no positional arguments along with Named. Text within
+// quotes and :: casts are left alone.
+func Named(values interface{}) QueryOption {
+	return queryOptionFunc(func(options *queryOptions) {
+		options.named = values
+	})
+}
+
+// bindNamed rewrites the :name placeholders of conditions into the dialect's
+// positional ones and appends their values to args.
+func bindNamed(dialect Dialect, conditions string, named interface{}, args []interface{}) (string, []interface{}, error) {
+	values := reflect.Indirect(reflect.ValueOf(named))
+	if values.Kind() != reflect.Map && values.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("named parameters from %T, want a map or a struct", named)
+	}
+	if values.Kind() == reflect.Map && values.Type().Key().Kind() != reflect.String {
+		return "", nil, fmt.Errorf("named parameters from %T, want string keys", named)
+	}
+	if len(args) > 0 && dialect.Placeholder(1) == dialect.Placeholder(2) {
+		return "", nil, fmt.Errorf("dialect %s cannot bind named parameters along with positional ones", dialect.Name())
+	}
+	buffer := strings.Builder{}
+	quote := byte(0)
+	for i := 0; i < len(conditions); i++ {
+		c := conditions[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ':' && i+1 < len(conditions) && conditions[i+1] == ':':
+			buffer.WriteString("::")
+			i++
+			continue
+		case c == ':' && i+1 < len(conditions) && isNameStart(conditions[i+1]):
+			end := i + 1
+			for end < len(conditions) && (isNameStart(conditions[end]) || conditions[end] >= '0' && conditions[end] <= '9') {
+				end++
+			}
+			name := conditions[i+1 : end]
+			value, ok := namedValue(values, name)
+			if !ok {
+				return "", nil, fmt.Errorf("no value for named parameter :%s", name)
+			}
+			args = append(args, bindValue(value))
+			buffer.WriteString(dialect.Placeholder(len(args)))
+			i = end - 1
+			continue
+		}
+		buffer.WriteByte(c)
+	}
+	return buffer.String(), args, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func namedValue(values reflect.Value, name string) (interface{}, bool) {
+	if values.Kind() == reflect.Map {
+		value := values.MapIndex(reflect.ValueOf(name).Convert(values.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	}
+	field, ok := values.Type().FieldByNameFunc(func(fieldName string) bool {
+		return strings.EqualFold(fieldName, name)
+	})
+	if !ok || field.PkgPath != "" {
+		return nil, false
+	}
+	return values.FieldByIndex(field.Index).Interface(), true
+}
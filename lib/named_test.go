@@ -0,0 +1,62 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindNamed(t *testing.T) {
+	type values struct {
+		Name string
+		Min  int
+	}
+	cases := []struct {
+		dialect    Dialect
+		conditions string
+		named      interface{}
+		args       []interface{}
+		expected   string
+		values     []interface{}
+	}{
+		{Postgres{}, "where o.name = :name and o.n > :min", map[string]interface{}{"name": "a", "min": 1}, nil,
+			"where o.name = $1 and o.n > $2", []interface{}{"a", 1}},
+		{Postgres{}, "where o.id = $1 and o.name = :name", &values{Name: "a"}, []interface{}{7},
+			"where o.id = $1 and o.name = $2", []interface{}{7, "a"}},
+		{Postgres{}, "where o.name = :Name or o.alias = :name", values{Name: "a"}, nil,
+			"where o.name = $1 or o.alias = $2", []interface{}{"a", "a"}},
+		{Postgres{}, "where o.name = ':name' and o.day = :min::date", map[string]int{"min": 3}, nil,
+			"where o.name = ':name' and o.day = $1::date", []interface{}{3}},
+		{MySQL{}, "where o.name = :name", map[string]string{"name": "a"}, nil,
+			"where o.name = ?", []interface{}{"a"}},
+	}
+	for _, c := range cases {
+		actual, args, err := bindNamed(c.dialect, c.conditions, c.named, c.args)
+		if err != nil {
+			t.Errorf("%q: %v", c.conditions, err)
+			continue
+		}
+		if actual != c.expected || !reflect.DeepEqual(args, c.values) {
+			t.Errorf("%q: expected %q %v, got %q %v", c.conditions, c.expected, c.values, actual, args)
+		}
+	}
+}
+
+func TestBindNamedErrors(t *testing.T) {
+	cases := []struct {
+		dialect    Dialect
+		conditions string
+		named      interface{}
+		args       []interface{}
+	}{
+		{Postgres{}, "where o.name = :missing", map[string]interface{}{"name": "a"}, nil},
+		{Postgres{}, "where o.name = :name", map[int]string{1: "a"}, nil},
+		{Postgres{}, "where o.name = :name", "a", nil},
+		{Postgres{}, "where o.name = :hidden", struct{ hidden string }{"a"}, nil},
+		{MySQL{}, "where o.name = :name and o.id = ?", map[string]string{"name": "a"}, []interface{}{7}},
+	}
+	for _, c := range cases {
+		if _, _, err := bindNamed(c.dialect, c.conditions, c.named, c.args); err == nil {
+			t.Errorf("%q with %T: expected an error", c.conditions, c.named)
+		}
+	}
+}
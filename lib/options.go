@@ -0,0 +1,124 @@
+package srm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// QueryOption values can be mixed with the bind arguments of Query and its
+// variants; they change how the query is built and are never bound.
+type QueryOption interface {
+	applyQuery(options *queryOptions)
+}
+
+type queryOptions struct {
+	plan     *fetchPlan
+	unscoped bool
+	criteria *Criteria
+	named    interface{}
+	page     *page
+	keyset   bool
+	after    interface{}
+}
+
+type queryOptionFunc func(options *queryOptions)
+
+func (o queryOptionFunc) applyQuery(options *queryOptions) {
+	o(options)
+}
+
+func splitArgs(args []interface{}) ([]interface{}, queryOptions) {
+	options := queryOptions{}
+	bindArgs := make([]interface{}, 0, len(args))
+	for i := range args {
+		if option, ok := args[i].(QueryOption); ok {
+			option.applyQuery(&options)
+		} else {
+			bindArgs = append(bindArgs, args[i])
+		}
+	}
+	return bindArgs, options
+}
+
+// Fetch joins only the listed relation paths, such as "Detail.Master1", and
+// their prefixes. Relations left out are filled with stubs holding just the
+// id.
+func Fetch(paths ...string) QueryOption {
+	return queryOptionFunc(func(options *queryOptions) {
+		plan := options.fetchPlan()
+		for i := range paths {
+			parts := strings.Split(paths[i], ".")
+			for j := range parts {
+				plan.paths[strings.Join(parts[:j+1], ".")] = true
+			}
+		}
+	})
+}
+
+// MaxDepth joins relations up to depth levels away from the queried entity,
+// on top of the paths given to Fetch. MaxDepth(0) joins nothing.
+func MaxDepth(depth int) QueryOption {
+	return queryOptionFunc(func(options *queryOptions) {
+		options.fetchPlan().maxDepth = depth
+	})
+}
+
+// Unscoped includes soft deleted rows, both queried and joined.
+func Unscoped() QueryOption {
+	return queryOptionFunc(func(options *queryOptions) {
+		options.unscoped = true
+	})
+}
+
+func (o *queryOptions) fetchPlan() *fetchPlan {
+	if o.plan == nil {
+		o.plan = &fetchPlan{paths: make(map[string]bool), maxDepth: -1}
+	}
+	return o.plan
+}
+
+// fetchPlan decides which relations a query joins. A nil plan joins the
+// whole graph.
+type fetchPlan struct {
+	paths    map[string]bool
+	maxDepth int
+	prefix   string
+}
+
+func (o *fetchPlan) fetches(field string) bool {
+	if o == nil {
+		return true
+	}
+	path := o.path(field)
+	if o.maxDepth >= 0 && strings.Count(path, ".") < o.maxDepth {
+		return true
+	}
+	return o.paths[path]
+}
+
+func (o *fetchPlan) child(field string) *fetchPlan {
+	if o == nil {
+		return nil
+	}
+	return &fetchPlan{paths: o.paths, maxDepth: o.maxDepth, prefix: o.path(field)}
+}
+
+func (o *fetchPlan) path(field string) string {
+	if o.prefix == "" {
+		return field
+	}
+	return o.prefix + "." + field
+}
+
+func (o *fetchPlan) key() string {
+	if o == nil {
+		return ""
+	}
+	paths := make([]string, 0, len(o.paths))
+	for path := range o.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return fmt.Sprintf(";%d;%s", o.maxDepth, strings.Join(paths, ","))
+}
@@ -0,0 +1,138 @@
+package srm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"github.com/gabrielmorenobrc/go-tkt/lib"
+)
+
+type page struct {
+	offset int
+	limit  int
+}
+
+// Page returns limit rows starting at offset. Both are bound as parameters,
+// so every page shares one prepared statement.
+func Page(offset int, limit int) QueryOption {
+	return queryOptionFunc(func(options *queryOptions) {
+		options.page = &page{offset: offset, limit: limit}
+	})
+}
+
+// After returns only the rows with an id greater than lastId, ordered by id,
+// for keyset pagination along with Page(0, n). The conditions must not order
+// rows themselves.
+func After(lastId interface{}) QueryOption {
+	return queryOptionFunc(func(options *queryOptions) {
+		options.keyset = true
+		options.after = lastId
+	})
+}
+
+// prepareConditions binds named parameters and renders criteria, common to
+// queries and counts.
+func (o *Trx) prepareConditions(objectType reflect.Type, conditions string, args []interface{}, options queryOptions) (string, []interface{}, error) {
+	var err error
+	if options.named != nil {
+		conditions, args, err = bindNamed(o.dialect, conditions, options.named, args)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if options.criteria != nil {
+		if strings.TrimSpace(conditions) != "" {
+			return "", nil, fmt.Errorf("conditions %q given along with criteria", conditions)
+		}
+		conditions, args, err = options.criteria.build(o, objectType, options.plan, args)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return conditions, args, nil
+}
+
+// paginate applies After and Page to conditions, idColumn being the aliased
+// id of the queried entity. Their placeholders follow those of conditions,
+// keeping positional dialects in order.
+func (o *Trx) paginate(conditions string, args []interface{}, options queryOptions, idColumn string) (string, []interface{}, error) {
+	if options.keyset {
+		trimmed := strings.TrimSpace(conditions)
+		lower := strings.ToLower(trimmed)
+		if strings.Contains(lower, "order by") {
+			return "", nil, fmt.Errorf("conditions %q cannot order rows paginated with After", conditions)
+		}
+		args = append(args, bindValue(options.after))
+		keyset := idColumn + " > " + o.dialect.Placeholder(len(args))
+		switch {
+		case trimmed == "":
+			conditions = "where " + keyset
+		case strings.HasPrefix(lower, "where "):
+			conditions = "where (" + trimmed[len("where "):] + ") and " + keyset
+		default:
+			return "", nil, fmt.Errorf("conditions %q are not a where clause", conditions)
+		}
+		conditions += " order by " + idColumn
+	}
+	if options.page != nil {
+		args = append(args, options.page.limit, options.page.offset)
+		conditions += " " + o.dialect.Paginate(o.dialect.Placeholder(len(args)-1), o.dialect.Placeholder(len(args)))
+	}
+	return conditions, args, nil
+}
+
+// Count returns how many rows Query would return with the same conditions
+// and arguments, Page and After aside.
+func (o *Trx) Count(template interface{}, conditions string, args ...interface{}) (int64, error) {
+	return o.CountContext(context.Background(), template, conditions, args...)
+}
+
+func (o *Trx) CountContext(ctx context.Context, template interface{}, conditions string, args ...interface{}) (int64, error) {
+	objectType := reflect.TypeOf(template)
+	args, options := splitArgs(args)
+	if options.criteria != nil {
+		criteria := *options.criteria
+		criteria.orders = nil
+		options.criteria = &criteria
+	}
+	conditions, args, err := o.prepareConditions(objectType, conditions, args, options)
+	if err != nil {
+		return 0, err
+	}
+	o.checkMaps()
+	key := "count " + objectType.Name() + options.plan.key()
+	if options.unscoped {
+		key += ";unscoped"
+	}
+	sql, ok := o.queryMap[key]
+	if !ok {
+		sql = o.buildCountSql(objectType, key, options.plan, !options.unscoped)
+	}
+	sql = "select count(*) from (" + sql + " " + conditions + ") c"
+	tkt.Logger("orm").Println(sql)
+	stmt, ok := o.stmtMap[sql]
+	if !ok {
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var count int64
+	if err := stmt.QueryRowContext(ctx, args...).Scan(&count); err != nil {
+		return 0, o.dialect.Classify(err)
+	}
+	return count, nil
+}
+
+// buildCountSql selects just the id over the joins of buildQuerySql, which
+// conditions may refer to and which drop rows whose required relations are
+// soft deleted. Rows are counted around it, leaving the conditions intact.
+func (o *Trx) buildCountSql(objectType reflect.Type, key string, plan *fetchPlan, scoped bool) string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	sql := "select o." + o.column(objectType.Field(0)) + " from " + o.scopedTable(objectType, scoped) + " o"
+	sql += o.buildMtoJoins(o.buildMtoList(objectType), "o", false, plan, scoped)
+	o.queryMap[key] = sql
+	return sql
+}
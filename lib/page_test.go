@@ -0,0 +1,51 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	cases := []struct {
+		dialect    Dialect
+		conditions string
+		args       []interface{}
+		options    []interface{}
+		expected   string
+		values     []interface{}
+	}{
+		{Postgres{}, "where o.name = $1", []interface{}{"a"}, []interface{}{Page(20, 10)},
+			"where o.name = $1 limit $2 offset $3", []interface{}{"a", 10, 20}},
+		{Postgres{}, "", nil, []interface{}{After(int64(5)), Page(0, 10)},
+			`where "o"."id" > $1 order by "o"."id" limit $2 offset $3`, []interface{}{int64(5), 10, 0}},
+		{Postgres{}, "where o.name = $1 or o.name = $2", []interface{}{"a", "b"}, []interface{}{After(int64(5))},
+			`where (o.name = $1 or o.name = $2) and "o"."id" > $3 order by "o"."id"`, []interface{}{"a", "b", int64(5)}},
+		{MySQL{}, "", nil, []interface{}{Page(0, 10)},
+			" limit ? offset ?", []interface{}{10, 0}},
+		{Postgres{}, "where o.name = $1", []interface{}{"a"}, nil,
+			"where o.name = $1", []interface{}{"a"}},
+	}
+	for _, c := range cases {
+		trx := &Trx{dialect: c.dialect}
+		_, options := splitArgs(c.options)
+		idColumn := trx.dialect.Quote("o") + "." + trx.dialect.Quote("id")
+		actual, args, err := trx.paginate(c.conditions, c.args, options, idColumn)
+		if err != nil {
+			t.Errorf("%q: %v", c.conditions, err)
+			continue
+		}
+		if actual != c.expected || !reflect.DeepEqual(args, c.values) {
+			t.Errorf("%q: expected %q %v, got %q %v", c.conditions, c.expected, c.values, actual, args)
+		}
+	}
+}
+
+func TestPaginateErrors(t *testing.T) {
+	trx := &Trx{dialect: Postgres{}}
+	_, options := splitArgs([]interface{}{After(1)})
+	for _, conditions := range []string{"where o.id > 0 order by o.name", "o.id > 0"} {
+		if _, _, err := trx.paginate(conditions, nil, options, "o.id"); err == nil {
+			t.Errorf("%q: expected an error", conditions)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package srm
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+// Ref is a lazy many-to-one relation. It maps to the same foreign key column
+// as a T field would, but queries read only the id and the target is loaded
+// on the first Get through the Trx that read the owner. Use *Ref[T] for a
+// nullable relation.
+type Ref[T any] struct {
+	id    interface{}
+	trx   *Trx
+	value *T
+}
+
+type reference interface {
+	targetType() reflect.Type
+	bind(trx *Trx)
+}
+
+var referenceType = reflect.TypeOf((*reference)(nil)).Elem()
+
+// RefTo references the entity with the given id, converted to the type of
+// its Id field when possible, so RefTo[Master1](1) holds an int64.
+func RefTo[T any](id interface{}) Ref[T] {
+	ref := Ref[T]{id: id}
+	if id == nil {
+		return ref
+	}
+	if value, err := driver.DefaultParameterConverter.ConvertValue(id); err == nil && value != nil {
+		if converted, err := convertId(value, ref.targetType().Field(0).Type); err == nil {
+			ref.id = converted.Interface()
+		}
+	}
+	return ref
+}
+
+// RefOf references an already loaded entity, so Get will not hit the
+// database.
+func RefOf[T any](entity *T) Ref[T] {
+	return Ref[T]{value: entity}
+}
+
+// Id returns the id of the target, nil for an empty reference.
+func (o Ref[T]) Id() interface{} {
+	if o.value != nil {
+		return reflect.ValueOf(o.value).Elem().Field(0).Interface()
+	}
+	return o.id
+}
+
+func (o Ref[T]) Loaded() bool {
+	return o.value != nil
+}
+
+func (o *Ref[T]) Get() (*T, error) {
+	return o.GetContext(context.Background())
+}
+
+func (o *Ref[T]) GetContext(ctx context.Context) (*T, error) {
+	if o.value != nil || o.id == nil {
+		return o.value, nil
+	}
+	if o.trx == nil {
+		return nil, fmt.Errorf("reference to %v %v is not bound to a transaction", o.targetType(), o.id)
+	}
+	value, err := FindContext[T](ctx, o.trx, o.id)
+	if err != nil {
+		return nil, err
+	}
+	o.value = value
+	return value, nil
+}
+
+func (o Ref[T]) Value() (driver.Value, error) {
+	id := o.Id()
+	if id == nil || reflect.ValueOf(id).IsZero() {
+		return nil, nil
+	}
+	value := idValue(reflect.ValueOf(id))
+	if valuer, ok := value.(driver.Valuer); ok {
+		return valuer.Value()
+	}
+	return driver.DefaultParameterConverter.ConvertValue(value)
+}
+
+func (o *Ref[T]) Scan(src interface{}) error {
+	o.value = nil
+	o.id = nil
+	if src == nil {
+		return nil
+	}
+	id, err := convertId(src, o.targetType().Field(0).Type)
+	if err != nil {
+		return err
+	}
+	o.id = id.Interface()
+	return nil
+}
+
+func (o Ref[T]) targetType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (o *Ref[T]) bind(trx *Trx) {
+	o.trx = trx
+}
+
+// IsReference reports whether fieldType is a Ref or a pointer to one.
+func IsReference(fieldType reflect.Type) bool {
+	return reflect.PtrTo(entityType(fieldType)).Implements(referenceType)
+}
+
+func referenceTarget(fieldType reflect.Type) reflect.Type {
+	return reflect.New(entityType(fieldType)).Interface().(reference).targetType()
+}
+
+func bindReference(value reflect.Value, trx *Trx) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	value.Addr().Interface().(reference).bind(trx)
+}
@@ -0,0 +1,313 @@
+package srm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+type ColumnInfo struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+type ForeignKeyInfo struct {
+	Name      string
+	Column    string
+	RefSchema string
+	RefTable  string
+	RefColumn string
+}
+
+// SchemaDialect is implemented by dialects able to inspect the catalog and
+// alter existing tables.
+type SchemaDialect interface {
+	TableColumns(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ColumnInfo, error)
+	ForeignKeys(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ForeignKeyInfo, error)
+	NormalizeType(columnType string) string
+	// The statement builders below receive quoted identifiers and return ""
+	// when the engine cannot perform the change.
+	AddColumn(table string, column string, columnType string, nullable bool) string
+	DropColumn(table string, column string) string
+	AlterColumn(table string, column string, columnType string, nullable bool) []string
+	AddForeignKey(table string, column string, refTable string, refColumn string) string
+	DropForeignKey(table string, name string) string
+}
+
+type columnSpec struct {
+	field     reflect.StructField
+	name      string
+	sqlType   string
+	nullable  bool
+	refType   reflect.Type
+	refColumn string
+	identity  bool
+}
+
+func buildColumnSpecs(dialect Dialect, objectType reflect.Type) []columnSpec {
+	specs := make([]columnSpec, 0)
+	columns := columnFields(objectType)
+	for i := range columns {
+		f := columns[i]
+		spec := columnSpec{field: f, name: ColumnName(f), sqlType: dialect.ColumnType(f)}
+		_, spec.nullable = NullableType(f.Type)
+		if IsRelation(f.Type) {
+			idField, _ := entityType(f.Type).FieldByName("Id")
+			spec.refType = entityType(f.Type)
+			spec.refColumn = ColumnName(idField)
+		} else if IsReference(f.Type) {
+			idField, _ := referenceTarget(f.Type).FieldByName("Id")
+			spec.refType = referenceTarget(f.Type)
+			spec.refColumn = ColumnName(idField)
+		}
+		if i == 0 {
+			generator, _ := IdGeneratorFor(objectType)
+			if identityDialect, ok := dialect.(IdentityDialect); ok && isIdentity(generator) {
+				spec.sqlType = identityDialect.IdentityType(spec.sqlType)
+				spec.identity = true
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+func splitFq(name string) (string, string) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+func scanColumns(r *sql.Rows) ([]ColumnInfo, error) {
+	defer r.Close()
+	columns := make([]ColumnInfo, 0)
+	for r.Next() {
+		c := ColumnInfo{}
+		if err := r.Scan(&c.Name, &c.Type, &c.Nullable); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, r.Err()
+}
+
+func scanForeignKeys(r *sql.Rows) ([]ForeignKeyInfo, error) {
+	defer r.Close()
+	keys := make([]ForeignKeyInfo, 0)
+	for r.Next() {
+		k := ForeignKeyInfo{}
+		if err := r.Scan(&k.Name, &k.Column, &k.RefSchema, &k.RefTable, &k.RefColumn); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, r.Err()
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+func normalizeWith(columnType string, aliases map[string]string) string {
+	t := strings.ToLower(strings.TrimSpace(spaces.ReplaceAllString(columnType, " ")))
+	t = strings.ReplaceAll(t, ", ", ",")
+	base, args := t, ""
+	if i := strings.Index(t, "("); i >= 0 {
+		base, args = strings.TrimSpace(t[:i]), t[i:]
+	}
+	if alias, ok := aliases[base]; ok {
+		base = alias
+	}
+	if alias, ok := aliases[base+args]; ok {
+		return alias
+	}
+	if base == "numeric" && args != "" && !strings.Contains(args, ",") {
+		args = strings.TrimSuffix(args, ")") + ",0)"
+	}
+	return base + args
+}
+
+var postgresAliases = map[string]string{
+	"character varying":           "varchar",
+	"int":                         "integer",
+	"int4":                        "integer",
+	"int8":                        "bigint",
+	"float4":                      "real",
+	"float8":                      "double precision",
+	"bool":                        "boolean",
+	"decimal":                     "numeric",
+	"timestamp without time zone": "timestamp",
+	"time without time zone":      "time",
+	"timestamp with time zone":    "timestamptz",
+}
+
+func (o Postgres) TableColumns(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ColumnInfo, error) {
+	r, err := tx.QueryContext(ctx, `select column_name,
+case when character_maximum_length is not null then data_type || '(' || character_maximum_length || ')'
+when data_type = 'numeric' and numeric_precision is not null then data_type || '(' || numeric_precision || ',' || coalesce(numeric_scale, 0) || ')'
+else data_type end,
+is_nullable = 'YES'
+from information_schema.columns
+where table_schema = coalesce(nullif($1, ''), current_schema()) and table_name = $2
+order by ordinal_position`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	return scanColumns(r)
+}
+
+func (o Postgres) ForeignKeys(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ForeignKeyInfo, error) {
+	r, err := tx.QueryContext(ctx, `select tc.constraint_name, kcu.column_name, ccu.table_schema, ccu.table_name, ccu.column_name
+from information_schema.table_constraints tc
+join information_schema.key_column_usage kcu on kcu.constraint_schema = tc.constraint_schema and kcu.constraint_name = tc.constraint_name
+join information_schema.constraint_column_usage ccu on ccu.constraint_schema = tc.constraint_schema and ccu.constraint_name = tc.constraint_name
+where tc.constraint_type = 'FOREIGN KEY' and tc.table_schema = coalesce(nullif($1, ''), current_schema()) and tc.table_name = $2`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(r)
+}
+
+func (o Postgres) NormalizeType(columnType string) string {
+	return normalizeWith(columnType, postgresAliases)
+}
+
+func (o Postgres) AddColumn(table string, column string, columnType string, nullable bool) string {
+	return "alter table " + table + " add column " + column + " " + columnType + nullability(nullable)
+}
+
+func (o Postgres) DropColumn(table string, column string) string {
+	return "alter table " + table + " drop column " + column
+}
+
+func (o Postgres) AlterColumn(table string, column string, columnType string, nullable bool) []string {
+	null := "set not null"
+	if nullable {
+		null = "drop not null"
+	}
+	return []string{
+		"alter table " + table + " alter column " + column + " type " + columnType,
+		"alter table " + table + " alter column " + column + " " + null,
+	}
+}
+
+func (o Postgres) AddForeignKey(table string, column string, refTable string, refColumn string) string {
+	return "alter table " + table + " add foreign key(" + column + ") references " + refTable + "(" + refColumn + ")"
+}
+
+func (o Postgres) DropForeignKey(table string, name string) string {
+	return "alter table " + table + " drop constraint " + name
+}
+
+var mysqlAliases = map[string]string{
+	"integer":          "int",
+	"int(11)":          "int",
+	"bigint(20)":       "bigint",
+	"bool":             "tinyint(1)",
+	"boolean":          "tinyint(1)",
+	"double precision": "double",
+	"decimal":          "numeric",
+}
+
+func (o MySQL) TableColumns(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ColumnInfo, error) {
+	r, err := tx.QueryContext(ctx, `select column_name, column_type, is_nullable = 'YES'
+from information_schema.columns
+where table_schema = coalesce(nullif(?, ''), database()) and table_name = ?
+order by ordinal_position`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	return scanColumns(r)
+}
+
+func (o MySQL) ForeignKeys(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ForeignKeyInfo, error) {
+	r, err := tx.QueryContext(ctx, `select constraint_name, column_name, referenced_table_schema, referenced_table_name, referenced_column_name
+from information_schema.key_column_usage
+where table_schema = coalesce(nullif(?, ''), database()) and table_name = ? and referenced_table_name is not null`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(r)
+}
+
+func (o MySQL) NormalizeType(columnType string) string {
+	return normalizeWith(columnType, mysqlAliases)
+}
+
+func (o MySQL) AddColumn(table string, column string, columnType string, nullable bool) string {
+	return "alter table " + table + " add column " + column + " " + columnType + nullability(nullable)
+}
+
+func (o MySQL) DropColumn(table string, column string) string {
+	return "alter table " + table + " drop column " + column
+}
+
+func (o MySQL) AlterColumn(table string, column string, columnType string, nullable bool) []string {
+	return []string{"alter table " + table + " modify column " + column + " " + columnType + nullability(nullable)}
+}
+
+func (o MySQL) AddForeignKey(table string, column string, refTable string, refColumn string) string {
+	return "alter table " + table + " add foreign key(" + column + ") references " + refTable + "(" + refColumn + ")"
+}
+
+func (o MySQL) DropForeignKey(table string, name string) string {
+	return "alter table " + table + " drop foreign key " + name
+}
+
+func (o SQLite) TableColumns(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ColumnInfo, error) {
+	r, err := tx.QueryContext(ctx, `select name, type, "notnull" = 0 from `+sqlitePragma(schema, "table_info", table))
+	if err != nil {
+		return nil, err
+	}
+	return scanColumns(r)
+}
+
+func (o SQLite) ForeignKeys(ctx context.Context, tx *sql.Tx, schema string, table string) ([]ForeignKeyInfo, error) {
+	r, err := tx.QueryContext(ctx, `select cast(id as text), "from", '`+strings.ReplaceAll(schema, "'", "''")+`', "table", "to" from `+sqlitePragma(schema, "foreign_key_list", table))
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(r)
+}
+
+func sqlitePragma(schema string, pragma string, table string) string {
+	if schema == "" {
+		schema = "main"
+	}
+	return fmt.Sprintf("pragma_%s('%s', '%s')", pragma, strings.ReplaceAll(table, "'", "''"), strings.ReplaceAll(schema, "'", "''"))
+}
+
+func (o SQLite) NormalizeType(columnType string) string {
+	return normalizeWith(columnType, map[string]string{})
+}
+
+func (o SQLite) AddColumn(table string, column string, columnType string, nullable bool) string {
+	return "alter table " + table + " add column " + column + " " + columnType + nullability(nullable)
+}
+
+func (o SQLite) DropColumn(table string, column string) string {
+	return "alter table " + table + " drop column " + column
+}
+
+func (o SQLite) AlterColumn(table string, column string, columnType string, nullable bool) []string {
+	return nil
+}
+
+func (o SQLite) AddForeignKey(table string, column string, refTable string, refColumn string) string {
+	return ""
+}
+
+func (o SQLite) DropForeignKey(table string, name string) string {
+	return ""
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return ""
+	}
+	return " not null"
+}
@@ -0,0 +1,28 @@
+package srm
+
+import (
+	"testing"
+)
+
+func TestNormalizeWith(t *testing.T) {
+	cases := []struct {
+		dialect  SchemaDialect
+		in       string
+		expected string
+	}{
+		{Postgres{}, "character varying(255)", "varchar(255)"},
+		{Postgres{}, "CHARACTER  VARYING (255)", "varchar(255)"},
+		{Postgres{}, "int8", "bigint"},
+		{Postgres{}, "timestamp without time zone", "timestamp"},
+		{Postgres{}, "numeric(10, 2)", "numeric(10,2)"},
+		{Postgres{}, "decimal(10)", "numeric(10,0)"},
+		{Postgres{}, "double precision", "double precision"},
+		{SQLite{}, "VARCHAR(255)", "varchar(255)"},
+		{SQLite{}, " integer ", "integer"},
+	}
+	for _, c := range cases {
+		if actual := c.dialect.NormalizeType(c.in); actual != c.expected {
+			t.Errorf("%T %q: expected %q, got %q", c.dialect, c.in, c.expected, actual)
+		}
+	}
+}
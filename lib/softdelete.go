@@ -0,0 +1,128 @@
+package srm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// softDeleteIndex returns the index within columnFields of the field tagged
+// `softdelete:"true"`, or -1 when rows of objectType are deleted for real.
+func softDeleteIndex(objectType reflect.Type) int {
+	fields := columnFields(objectType)
+	for i := 1; i < len(fields); i++ {
+		if fields[i].Tag.Get("softdelete") == "true" {
+			return i
+		}
+	}
+	return -1
+}
+
+// scopedTable returns what to select objectType from: its table or, when
+// scoped and soft deleted, only its live rows.
+func (o *Trx) scopedTable(objectType reflect.Type, scoped bool) string {
+	index := softDeleteIndex(objectType)
+	if !scoped || index < 0 {
+		return o.table(objectType)
+	}
+	return "(select * from " + o.table(objectType) + " where " + o.column(columnFields(objectType)[index]) + " is null)"
+}
+
+// scopedJoin returns the extra join condition leaving out soft deleted rows
+// of objectType under alias.
+func (o *Trx) scopedJoin(objectType reflect.Type, alias string, scoped bool) string {
+	index := softDeleteIndex(objectType)
+	if !scoped || index < 0 {
+		return ""
+	}
+	return " and " + alias + "." + o.column(columnFields(objectType)[index]) + " is null"
+}
+
+// scopedStub reports whether the relation mto, left out of the fetch plan,
+// is still joined on its id alone so that soft deleted targets drop the row,
+// or leave a nil stub when optional, as they do when fetched.
+func (o *Trx) scopedStub(mto reflect.StructField, scoped bool) bool {
+	return scoped && softDeleteIndex(entityType(mto.Type)) >= 0
+}
+
+// HardDelete removes the row of entity even when its type is soft deleted.
+func (o *Trx) HardDelete(entity interface{}) error {
+	return o.HardDeleteContext(context.Background(), entity)
+}
+
+// softDelete stamps the soft delete field of a live row, also matching and
+// bumping the version of versioned entities.
+func (o *Trx) softDelete(ctx context.Context, object reflect.Value, index int) error {
+	objectType := object.Type()
+	fields := columnFields(objectType)
+	field := fields[index]
+	if valueType, ok := NullableType(field.Type); !ok || valueType != reflect.TypeOf(time.Time{}) {
+		return fmt.Errorf("%s.%s: soft delete fields must be nullable times", objectType.Name(), field.Name)
+	}
+	version, err := versionIndex(objectType)
+	if err != nil {
+		return err
+	}
+	key := objectType.Name() + " soft"
+	sql, ok := o.deleteMap[key]
+	if !ok {
+		sql = o.buildSoftDeleteSql(objectType, key, index, version)
+	}
+	stmt, ok := o.stmtMap[sql]
+	if !ok {
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return err
+		}
+	}
+	t := now()
+	buffer := []interface{}{t}
+	var current int64
+	if version > 0 {
+		current = object.FieldByIndex(fields[version].Index).Int()
+		buffer = append(buffer, current+1)
+	}
+	buffer = append(buffer, idValue(object.Field(0)))
+	if version > 0 {
+		buffer = append(buffer, current)
+	}
+	r, err := stmt.ExecContext(ctx, buffer...)
+	if err != nil {
+		return o.dialect.Classify(err)
+	}
+	if version > 0 {
+		if err := checkVersion(r, object, current); err != nil {
+			return err
+		}
+		object.FieldByIndex(fields[version].Index).SetInt(current + 1)
+	}
+	of := object.FieldByIndex(field.Index)
+	if of.Kind() == reflect.Ptr {
+		of.Set(reflect.ValueOf(&t))
+	} else {
+		of.Field(0).Set(reflect.ValueOf(t))
+		of.Field(1).SetBool(true)
+	}
+	o.forget(object)
+	return nil
+}
+
+func (o *Trx) buildSoftDeleteSql(objectType reflect.Type, key string, index int, version int) string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	fields := columnFields(objectType)
+	deleted := o.column(fields[index])
+	sql := `update ` + o.table(objectType) + ` set ` + deleted + ` = ` + o.dialect.Placeholder(1)
+	p := 2
+	if version > 0 {
+		sql += `, ` + o.column(fields[version]) + ` = ` + o.dialect.Placeholder(p)
+		p++
+	}
+	sql += ` where ` + o.column(fields[0]) + ` = ` + o.dialect.Placeholder(p) + ` and ` + deleted + ` is null`
+	if version > 0 {
+		sql += ` and ` + o.column(fields[version]) + ` = ` + o.dialect.Placeholder(p+1)
+	}
+	o.deleteMap[key] = sql
+	return sql
+}
@@ -0,0 +1,36 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSoftDeleteIndex(t *testing.T) {
+	type softDeleted struct {
+		Id      int64
+		Name    string
+		Deleted *time.Time `softdelete:"true"`
+	}
+	type notSoftDeleted struct {
+		Id      int64
+		Deleted *time.Time `softdelete:"false"`
+	}
+	type untagged struct {
+		Id      int64
+		Deleted *time.Time
+	}
+	cases := []struct {
+		template interface{}
+		expected int
+	}{
+		{softDeleted{}, 2},
+		{notSoftDeleted{}, -1},
+		{untagged{}, -1},
+	}
+	for _, c := range cases {
+		if actual := softDeleteIndex(reflect.TypeOf(c.template)); actual != c.expected {
+			t.Errorf("%T: expected %d, got %d", c.template, c.expected, actual)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package srm
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
 	"fmt"
@@ -18,194 +19,479 @@ type Trx struct {
 	updateMap map[string]string
 	deleteMap map[string]string
 	stmtMap   map[string]*sql.Stmt
+	snapshots map[snapshotKey][]interface{}
 	mux       sync.Mutex
 	active    bool
+	dialect   Dialect
 }
 
-func (o *Trx) Commit() {
-	tkt.CheckErr(o.tx.Commit())
+func (o *Trx) Commit() error {
+	err := o.tx.Commit()
 	o.active = false
+	return err
 }
 
-func (o *Trx) Rollback() {
+func (o *Trx) Rollback() error {
+	var err error
 	if o.active {
-		tkt.CheckErr(o.tx.Rollback())
+		err = o.tx.Rollback()
 	}
 	o.active = false
+	return err
+}
 
+func (o *Trx) Close() error {
+	return o.Rollback()
 }
 
-func (o *Trx) Close() {
-	tkt.CheckErr(o.db.Close())
+func (o *Trx) Query(template interface{}, conditions string, args ...interface{}) (interface{}, error) {
+	return o.QueryContext(context.Background(), template, conditions, args...)
 }
 
-func (o *Trx) Query(template interface{}, conditions string, args ...interface{}) interface{} {
+func (o *Trx) QueryContext(ctx context.Context, template interface{}, conditions string, args ...interface{}) (interface{}, error) {
 	objectType := reflect.TypeOf(template)
+	args, options := splitArgs(args)
+	plan := options.plan
+	conditions, args, err := o.prepareConditions(objectType, conditions, args, options)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args, err = o.paginate(conditions, args, options, "o."+o.column(objectType.Field(0)))
+	if err != nil {
+		return nil, err
+	}
 	o.checkMaps()
-	sql, ok := o.queryMap[objectType.Name()]
+	key := objectType.Name() + plan.key()
+	if options.unscoped {
+		key += ";unscoped"
+	}
+	sql, ok := o.queryMap[key]
 	if !ok {
-		sql = o.buildQuerySql(objectType)
+		sql = o.buildQuerySql(objectType, key, plan, !options.unscoped)
 	}
 	sql += " " + conditions
 	tkt.Logger("orm").Println(sql)
 	stmt, ok := o.stmtMap[sql]
 	if !ok {
-		stmt = o.createStmt(sql)
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return nil, err
+		}
 	}
-	buffer := o.buildReadBufferForType(objectType)
-	r, err := stmt.Query(args...)
-	tkt.CheckErr(err)
+	buffer := o.buildReadBufferForType(objectType, plan)
+	r, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, o.dialect.Classify(err)
+	}
+	defer r.Close()
 	arr := reflect.MakeSlice(reflect.SliceOf(objectType), 0, 0)
 	for r.Next() {
-		tkt.CheckErr(r.Scan(buffer...))
-		object, _ := o.readBufferForType(buffer, objectType, 0)
+		if err := r.Scan(buffer...); err != nil {
+			return nil, err
+		}
+		object, _, err := o.readBufferForType(buffer, objectType, 0, plan)
+		if err != nil {
+			return nil, err
+		}
 		arr = reflect.Append(arr, *object)
 	}
-	return arr.Interface()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	r.Close()
+	if err := o.loadEager(ctx, arr, objectType); err != nil {
+		return nil, err
+	}
+	for i := 0; i < arr.Len(); i++ {
+		if err := o.afterLoadDeep(arr.Index(i), plan); err != nil {
+			return nil, err
+		}
+	}
+	return arr.Interface(), nil
 }
 
-func (o *Trx) Find(template interface{}, id int64) interface{} {
-	r := o.Query(template, "where o.Id = $1", id)
+func (o *Trx) Find(template interface{}, id interface{}, options ...QueryOption) (interface{}, error) {
+	return o.FindContext(context.Background(), template, id, options...)
+}
+
+func (o *Trx) FindContext(ctx context.Context, template interface{}, id interface{}, options ...QueryOption) (interface{}, error) {
+	args := []interface{}{idValue(reflect.ValueOf(id))}
+	for i := range options {
+		args = append(args, options[i])
+	}
+	r, err := o.QueryContext(ctx, template, "where o."+o.dialect.Quote("id")+" = "+o.dialect.Placeholder(1), args...)
+	if err != nil {
+		return nil, err
+	}
 	value := reflect.ValueOf(r)
 	if value.Len() == 0 {
-		return nil
+		return nil, nil
 	} else {
 		v := value.Index(0)
-		return reflect.Indirect(v).Addr().Interface()
+		return reflect.Indirect(v).Addr().Interface(), nil
 	}
 }
 
-func (o *Trx) Persist(entity interface{}) {
+func (o *Trx) Persist(entity interface{}) error {
+	return o.PersistContext(context.Background(), entity)
+}
+
+func (o *Trx) PersistContext(ctx context.Context, entity interface{}) error {
 	o.checkMaps()
 	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	if err := o.beforePersist(object); err != nil {
+		return err
+	}
+	if err := o.cascadePersist(ctx, object); err != nil {
+		return err
+	}
+	if err := o.persist(ctx, object); err != nil {
+		return err
+	}
+	return o.afterPersist(object)
+}
+
+func (o *Trx) persist(ctx context.Context, object reflect.Value) error {
 	objectType := object.Type()
-	sql, ok := o.insertMap[objectType.Name()]
+	generator, err := IdGeneratorFor(objectType)
+	if err != nil {
+		return err
+	}
+	if err := initVersion(object); err != nil {
+		return err
+	}
+	if err := stampCreated(object); err != nil {
+		return err
+	}
+	idField := object.Field(0)
+	identity := idField.IsZero() && isIdentity(generator)
+	if idField.IsZero() && !identity {
+		id, err := generator.NextId(ctx, o, objectType)
+		if err != nil {
+			return err
+		}
+		if err := assignId(idField, id); err != nil {
+			return err
+		}
+	}
+	key := objectType.Name()
+	if identity {
+		key += " identity"
+	}
+	sql, ok := o.insertMap[key]
 	if !ok {
-		sql = o.buildInsertSql(objectType)
+		sql, err = o.buildInsertSql(objectType, identity)
+		if err != nil {
+			return err
+		}
 	}
 	stmt, ok := o.stmtMap[sql]
 	if !ok {
-		stmt = o.createStmt(sql)
-	}
-	name := FqTableName(objectType)
-	id := o.sequences.Next(name)
-	of := object.Field(0)
-	of.SetInt(id)
-	buffer := make([]interface{}, object.NumField())
-	for i := 0; i < object.NumField(); i++ {
-		of := object.Field(i)
-		if IsEntity(of.Type()) {
-			buffer[i] = of.FieldByName("Id").Interface()
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return err
+		}
+	}
+	buffer := o.insertArgs(object)
+	if identity {
+		err = o.insertIdentity(ctx, stmt, objectType, idField, buffer[1:])
+	} else {
+		_, err = stmt.ExecContext(ctx, buffer...)
+		err = o.dialect.Classify(err)
+	}
+	if err != nil {
+		return err
+	}
+	o.snapshot(object)
+	return nil
+}
+
+func (o *Trx) insertArgs(object reflect.Value) []interface{} {
+	fields := columnFields(object.Type())
+	buffer := make([]interface{}, len(fields))
+	for i := range fields {
+		of := object.FieldByIndex(fields[i].Index)
+		if IsRelation(of.Type()) {
+			buffer[i] = relationId(of)
 		} else {
 			buffer[i] = of.Interface()
 		}
 	}
-	_, err := stmt.Exec(buffer...)
-	tkt.CheckErr(err)
+	buffer[0] = idValue(object.Field(0))
+	return buffer
+}
+
+func (o *Trx) insertIdentity(ctx context.Context, stmt *sql.Stmt, objectType reflect.Type, idField reflect.Value, buffer []interface{}) error {
+	if o.dialect.(IdentityDialect).Returning(o.column(objectType.Field(0))) != "" {
+		id := newIdBuffer(idField.Type())
+		if err := stmt.QueryRowContext(ctx, buffer...).Scan(id); err != nil {
+			return o.dialect.Classify(err)
+		}
+		idField.Set(id.value)
+		return nil
+	}
+	r, err := stmt.ExecContext(ctx, buffer...)
+	if err != nil {
+		return o.dialect.Classify(err)
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return assignId(idField, id)
 }
 
-func (o *Trx) Update(entity interface{}) {
+func (o *Trx) Update(entity interface{}) error {
+	return o.UpdateContext(context.Background(), entity)
+}
+
+func (o *Trx) UpdateContext(ctx context.Context, entity interface{}) error {
 	o.checkMaps()
 	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	if err := o.beforeUpdate(object); err != nil {
+		return err
+	}
+	if err := o.cascadePersist(ctx, object); err != nil {
+		return err
+	}
 	objectType := object.Type()
-	sql, ok := o.updateMap[objectType.Name()]
+	fields := columnFields(objectType)
+	version, err := versionIndex(objectType)
+	if err != nil {
+		return err
+	}
+	changed, tracked := o.changedColumns(object)
+	if !tracked {
+		changed = make([]int, 0, len(fields))
+		for i := 1; i < len(fields); i++ {
+			changed = append(changed, i)
+		}
+	}
+	changed = updatableColumns(objectType, changed, version)
+	if len(changed) == 0 {
+		return nil
+	}
+	stamped, err := stampUpdated(object)
+	if err != nil {
+		return err
+	}
+	for _, i := range stamped {
+		found := false
+		for j := range changed {
+			found = found || changed[j] == i
+		}
+		if !found {
+			changed = append(changed, i)
+		}
+	}
+	if version > 0 {
+		changed = append(changed, version)
+	}
+	key := objectType.Name()
+	for i := range changed {
+		key += fmt.Sprintf(",%d", changed[i])
+	}
+	sql, ok := o.updateMap[key]
 	if !ok {
-		sql = o.buildUpdateSql(objectType)
+		sql = o.buildUpdateSql(objectType, key, changed, version)
 	}
 	stmt, ok := o.stmtMap[sql]
 	if !ok {
-		stmt = o.createStmt(sql)
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return err
+		}
 	}
-	buffer := make([]interface{}, object.NumField())
-	for i := 0; i < object.NumField(); i++ {
-		of := object.Field(i)
-		if IsEntity(of.Type()) {
-			buffer[i] = of.Elem().FieldByName("Id").Interface()
-		} else {
-			buffer[i] = of.Interface()
+	args := o.insertArgs(object)
+	buffer := make([]interface{}, 0, len(changed)+2)
+	for i := range changed {
+		buffer = append(buffer, args[changed[i]])
+	}
+	buffer = append(buffer, args[0])
+	var current int64
+	if version > 0 {
+		current = object.FieldByIndex(fields[version].Index).Int()
+		buffer[len(changed)-1] = current + 1
+		buffer = append(buffer, current)
+	}
+	r, err := stmt.ExecContext(ctx, buffer...)
+	if err != nil {
+		return o.dialect.Classify(err)
+	}
+	if version > 0 {
+		if err := checkVersion(r, object, current); err != nil {
+			return err
 		}
+		object.FieldByIndex(fields[version].Index).SetInt(current + 1)
 	}
-	_, err := stmt.Exec(buffer...)
-	tkt.CheckErr(err)
+	o.snapshot(object)
+	return nil
 }
 
-func (o *Trx) Delete(entity interface{}) {
+// Delete removes the row of entity, or stamps its soft delete field when it
+// has one.
+func (o *Trx) Delete(entity interface{}) error {
+	return o.DeleteContext(context.Background(), entity)
+}
+
+func (o *Trx) DeleteContext(ctx context.Context, entity interface{}) error {
 	o.checkMaps()
 	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	if err := o.beforeDelete(object); err != nil {
+		return err
+	}
+	if err := o.cascadeDelete(ctx, object, false); err != nil {
+		return err
+	}
+	if index := softDeleteIndex(object.Type()); index > 0 {
+		return o.softDelete(ctx, object, index)
+	}
+	return o.hardDelete(ctx, object)
+}
+
+func (o *Trx) HardDeleteContext(ctx context.Context, entity interface{}) error {
+	o.checkMaps()
+	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	if err := o.beforeDelete(object); err != nil {
+		return err
+	}
+	if err := o.cascadeDelete(ctx, object, true); err != nil {
+		return err
+	}
+	return o.hardDelete(ctx, object)
+}
+
+func (o *Trx) hardDelete(ctx context.Context, object reflect.Value) error {
 	objectType := object.Type()
+	version, err := versionIndex(objectType)
+	if err != nil {
+		return err
+	}
 	sql, ok := o.deleteMap[objectType.Name()]
 	if !ok {
-		sql = o.buildDeleteSql(objectType)
+		sql = o.buildDeleteSql(objectType, version)
 	}
 	stmt, ok := o.stmtMap[sql]
 	if !ok {
-		stmt = o.createStmt(sql)
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return err
+		}
+	}
+	buffer := []interface{}{idValue(object.Field(0))}
+	var current int64
+	if version > 0 {
+		current = object.FieldByIndex(columnFields(objectType)[version].Index).Int()
+		buffer = append(buffer, current)
+	}
+	r, err := stmt.ExecContext(ctx, buffer...)
+	if err != nil {
+		return o.dialect.Classify(err)
+	}
+	if version > 0 {
+		if err := checkVersion(r, object, current); err != nil {
+			return err
+		}
 	}
-	of := object.Field(0)
-	_, err := stmt.Exec(of.Interface())
-	tkt.CheckErr(err)
+	o.forget(object)
+	return nil
 }
 
-func (o *Trx) buildInsertSql(objectType reflect.Type) string {
+func (o *Trx) buildInsertSql(objectType reflect.Type, identity bool) (string, error) {
 	o.mux.Lock()
 	defer o.mux.Unlock()
-	name := FqTableName(objectType)
-	sql := `insert into ` + name + `(`
-	for i := 0; i < objectType.NumField(); i++ {
-		field := objectType.Field(i)
+	fields := columnFields(objectType)
+	key := objectType.Name()
+	returning := ""
+	if identity {
+		dialect, ok := o.dialect.(IdentityDialect)
+		if !ok {
+			return "", fmt.Errorf("dialect %s has no identity columns", o.dialect.Name())
+		}
+		returning = dialect.Returning(o.column(fields[0]))
+		fields = fields[1:]
+		key += " identity"
+	}
+	sql := `insert into ` + o.table(objectType) + `(`
+	for i := range fields {
+		field := fields[i]
 		if i > 0 {
 			sql += ", "
 		}
-		if IsEntity(field.Type) {
-			sql += field.Name + "_id"
-		} else {
-			sql += field.Name
-		}
+		sql += o.column(field)
 	}
 	sql += `) values(`
-	for i := 0; i < objectType.NumField(); i++ {
+	for i := range fields {
 		if i > 0 {
 			sql += ", "
 		}
-		sql += fmt.Sprintf("$%d", i+1)
+		sql += o.dialect.Placeholder(i + 1)
 	}
-	sql += `)`
-	o.insertMap[objectType.Name()] = sql
-	return sql
+	sql += `)` + returning
+	o.insertMap[key] = sql
+	return sql, nil
 }
 
-func (o *Trx) buildUpdateSql(objectType reflect.Type) string {
+// updatableColumns drops from changed the version, bumped by srm and never
+// set by the caller, and the create fields, which keep the time inserted even
+// for an entity built by hand.
+func updatableColumns(objectType reflect.Type, changed []int, version int) []int {
+	skip := map[int]bool{version: version > 0}
+	for _, i := range autoIndexes(objectType, "create") {
+		skip[i] = true
+	}
+	columns := make([]int, 0, len(changed))
+	for i := range changed {
+		if !skip[changed[i]] {
+			columns = append(columns, changed[i])
+		}
+	}
+	return columns
+}
+
+// buildUpdateSql sets the columns at the changed indexes of columnFields,
+// also matching the current version when version is positive.
+func (o *Trx) buildUpdateSql(objectType reflect.Type, key string, changed []int, version int) string {
 	o.mux.Lock()
 	defer o.mux.Unlock()
-	name := FqTableName(objectType)
-	sql := `update ` + name
-	for i := 1; i < objectType.NumField(); i++ {
-		field := objectType.Field(i)
+	fields := columnFields(objectType)
+	sql := `update ` + o.table(objectType) + ` set `
+	for i := range changed {
+		field := fields[changed[i]]
 		if i > 0 {
-			sql += ","
-		}
-		sql += " set"
-		if IsEntity(field.Type) {
-			sql += field.Name + "_id"
-		} else {
-			sql += field.Name
+			sql += ", "
 		}
-		sql += fmt.Sprintf(" = $%d", i+1)
+		sql += o.column(field) + " = " + o.dialect.Placeholder(i+1)
+	}
+	sql += ` where ` + o.column(fields[0]) + ` = ` + o.dialect.Placeholder(len(changed)+1)
+	if version > 0 {
+		sql += ` and ` + o.column(fields[version]) + ` = ` + o.dialect.Placeholder(len(changed)+2)
 	}
-	sql += ` where id == $1`
-	o.updateMap[objectType.Name()] = sql
+	o.updateMap[key] = sql
 	return sql
 }
 
-func (o *Trx) buildDeleteSql(objectType reflect.Type) string {
+func (o *Trx) buildDeleteSql(objectType reflect.Type, version int) string {
 	o.mux.Lock()
 	defer o.mux.Unlock()
-	name := FqTableName(objectType)
-	sql := `delete from ` + name + ` where id = $1`
-	o.updateMap[objectType.Name()] = sql
+	sql := `delete from ` + o.table(objectType) + ` where ` + o.column(objectType.Field(0)) + ` = ` + o.dialect.Placeholder(1)
+	if version > 0 {
+		sql += ` and ` + o.column(columnFields(objectType)[version]) + ` = ` + o.dialect.Placeholder(2)
+	}
+	o.deleteMap[objectType.Name()] = sql
 	return sql
 }
 
+func (o *Trx) Exec(sql string, args ...interface{}) (sql.Result, error) {
+	return o.ExecContext(context.Background(), sql, args...)
+}
+
+func (o *Trx) ExecContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error) {
+	tkt.Logger("srm").Println(sql)
+	r, err := o.tx.ExecContext(ctx, sql, args...)
+	return r, o.dialect.Classify(err)
+}
+
 func (o *Trx) RollbackOnPanic() {
 	if r := recover(); r != nil {
 		o.Rollback()
@@ -213,25 +499,53 @@ func (o *Trx) RollbackOnPanic() {
 	}
 }
 
-func (o *Trx) Init(db *sql.DB, tx *sql.Tx, sequences *tkt.Sequences) {
+func (o *Trx) Init(db *sql.DB, tx *sql.Tx, sequences *tkt.Sequences, dialect Dialect) {
 	o.db = db
 	o.tx = tx
 	o.active = true
 	o.sequences = sequences
+	o.dialect = dialect
 	o.mux = sync.Mutex{}
 }
 
-func (o *Trx) QueryMulti(templates []interface{}, joins *Joins, conditions string, args ...interface{}) [][]interface{} {
+func (o *Trx) Dialect() Dialect {
+	return o.dialect
+}
+
+func (o *Trx) QueryMulti(templates []interface{}, joins *Joins, conditions string, args ...interface{}) ([][]interface{}, error) {
+	return o.QueryMultiContext(context.Background(), templates, joins, conditions, args...)
+}
+
+func (o *Trx) QueryMultiContext(ctx context.Context, templates []interface{}, joins *Joins, conditions string, args ...interface{}) ([][]interface{}, error) {
 	o.checkMaps()
-	key := o.buildStmtKeyForMultiple(templates, joins, conditions)
+	args, options := splitArgs(args)
+	if options.criteria != nil {
+		return nil, fmt.Errorf("criteria are not supported by QueryMulti")
+	}
+	conditions, args, err := o.prepareConditions(nil, conditions, args, options)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args, err = o.paginate(conditions, args, options, "o1."+o.column(reflect.TypeOf(templates[0]).Field(0)))
+	if err != nil {
+		return nil, err
+	}
+	scoped := !options.unscoped
+	key := o.buildStmtKeyForMultiple(templates, joins, conditions, scoped)
 	var stmt *sql.Stmt
 	stmt, ok := o.stmtMap[key]
 	if !ok {
-		stmt = o.buildStmtForMultiple(key, templates, joins, conditions)
+		stmt, err = o.buildStmtForMultiple(ctx, key, templates, joins, conditions, scoped)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	r, err := stmt.Query(args...)
-	tkt.CheckErr(err)
+	r, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, o.dialect.Classify(err)
+	}
+	defer r.Close()
 
 	objectTypes := make([]reflect.Type, 0)
 	for i := range templates {
@@ -241,17 +555,22 @@ func (o *Trx) QueryMulti(templates []interface{}, joins *Joins, conditions strin
 	buffer := make([]interface{}, 0)
 	for i := range templates {
 		objectType := reflect.TypeOf(templates[i])
-		buffer = append(buffer, o.buildReadBufferForType(objectType)...)
+		buffer = append(buffer, o.buildReadBufferForType(objectType, nil)...)
 	}
 
 	arr := make([][]interface{}, 0)
 	for r.Next() {
-		tkt.CheckErr(r.Scan(buffer...))
+		if err := r.Scan(buffer...); err != nil {
+			return nil, err
+		}
 		objects := make([]interface{}, len(templates))
 		offset := 0
 		for i := range templates {
 			objectType := objectTypes[i]
-			object, n := o.readBufferForType(buffer, objectType, offset)
+			object, n, err := o.readBufferForType(buffer, objectType, offset, nil)
+			if err != nil {
+				return nil, err
+			}
 			if object == nil {
 				objects[i] = reflect.New(reflect.PtrTo(objectType)).Elem().Interface()
 			} else {
@@ -261,21 +580,38 @@ func (o *Trx) QueryMulti(templates []interface{}, joins *Joins, conditions strin
 		}
 		arr = append(arr, objects)
 	}
-	return arr
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	r.Close()
+	for i := range arr {
+		for j := range arr[i] {
+			object := reflect.ValueOf(arr[i][j])
+			if object.IsNil() {
+				continue
+			}
+			if err := o.afterLoadDeep(object.Elem(), nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return arr, nil
 }
 
-func (o *Trx) buildStmtForMultiple(key string, templates []interface{}, joins *Joins, conditions string) *sql.Stmt {
+func (o *Trx) buildStmtForMultiple(ctx context.Context, key string, templates []interface{}, joins *Joins, conditions string, scoped bool) (*sql.Stmt, error) {
 	o.mux.Lock()
 	defer o.mux.Unlock()
-	sql := o.buildSqlForMultiple(templates, joins, conditions)
+	sql := o.buildSqlForMultiple(templates, joins, conditions, scoped)
 	tkt.Logger("srm").Println(sql)
-	stmt, err := o.tx.Prepare(sql)
-	tkt.CheckErr(err)
+	stmt, err := o.tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
 	o.stmtMap[key] = stmt
-	return stmt
+	return stmt, nil
 }
 
-func (o *Trx) buildStmtKeyForMultiple(templates []interface{}, joins *Joins, conditions string) string {
+func (o *Trx) buildStmtKeyForMultiple(templates []interface{}, joins *Joins, conditions string, scoped bool) string {
 	buffer := bytes.Buffer{}
 	for i := range templates {
 		buffer.WriteString(".")
@@ -290,10 +626,13 @@ func (o *Trx) buildStmtKeyForMultiple(templates []interface{}, joins *Joins, con
 	}
 	buffer.WriteString(";")
 	buffer.WriteString(conditions)
+	if !scoped {
+		buffer.WriteString(";unscoped")
+	}
 	return buffer.String()
 }
 
-func (o *Trx)buildSqlForMultiple(templates []interface{}, joins *Joins, conditions string) string {
+func (o *Trx)buildSqlForMultiple(templates []interface{}, joins *Joins, conditions string, scoped bool) string {
 	sql := "select "
 	for i := range templates {
 		template := templates[i]
@@ -303,18 +642,17 @@ func (o *Trx)buildSqlForMultiple(templates []interface{}, joins *Joins, conditio
 		alias := fmt.Sprintf("o%d", i+1)
 		sql += o.buildSelectFieldsForTemplate(template, alias)
 	}
-	name := FqTableName(reflect.TypeOf(templates[0]))
-	sql += "\r\nfrom " + name + " o1"
-	sql += "\r\n" + o.buildFromMtoSqlForTemplate(templates[0], "o1")
-	sql += o.buildJoinSqlForTemplates(templates, joins)
+	sql += "\r\nfrom " + o.scopedTable(reflect.TypeOf(templates[0]), scoped) + " o1"
+	sql += "\r\n" + o.buildFromMtoSqlForTemplate(templates[0], "o1", scoped)
+	sql += o.buildJoinSqlForTemplates(templates, joins, scoped)
 	sql += "\r\n" + conditions
 	return sql
 }
 
-func (o *Trx) buildFromMtoSqlForTemplates(templates []interface{}, offset int) string {
+func (o *Trx) buildFromMtoSqlForTemplates(templates []interface{}, offset int, scoped bool) string {
 	sql := ""
 	for i := offset; i < len(templates); i++ {
-		joinSql := o.buildFromMtoSqlForTemplate(templates[i], fmt.Sprintf("o%d", i+1))
+		joinSql := o.buildFromMtoSqlForTemplate(templates[i], fmt.Sprintf("o%d", i+1), scoped)
 		if len(joinSql) > 0 {
 			if i > 0 {
 				sql += "\r\n"
@@ -327,17 +665,17 @@ func (o *Trx) buildFromMtoSqlForTemplates(templates []interface{}, offset int) s
 	return sql
 }
 
-func (o *Trx) buildFromMtoSqlForTemplate(template interface{}, path string) string {
+func (o *Trx) buildFromMtoSqlForTemplate(template interface{}, path string, scoped bool) string {
 	sql := ""
 	objectType := reflect.TypeOf(template)
 	mtos := o.buildMtoList(objectType)
 	if len(mtos) > 0 {
-		sql += o.buildMtoJoins(mtos, path)
+		sql += o.buildMtoJoins(mtos, path, false, nil, scoped)
 	}
 	return sql
 }
 
-func (o *Trx) buildJoinSqlForTemplates(templates []interface{}, joins *Joins) string {
+func (o *Trx) buildJoinSqlForTemplates(templates []interface{}, joins *Joins, scoped bool) string {
 	sql := ""
 	for i := 0; i < joins.Size(); i++ {
 		template := templates[i+1]
@@ -350,10 +688,9 @@ func (o *Trx) buildJoinSqlForTemplates(templates []interface{}, joins *Joins) st
 		} else {
 			sql += " "
 		}
-		name := FqTableName(objectType)
-		sql += name + " " + alias
+		sql += o.scopedTable(objectType, scoped) + " " + alias
 		if len(mtos) > 0 {
-			sql += o.buildMtoJoins(mtos, alias) + ")"
+			sql += o.buildMtoJoins(mtos, alias, false, nil, scoped) + ")"
 		}
 		sql += " on " + joins.On(i)
 	}
@@ -362,9 +699,10 @@ func (o *Trx) buildJoinSqlForTemplates(templates []interface{}, joins *Joins) st
 
 func (o *Trx) buildMtoList(objectType reflect.Type) []reflect.StructField {
 	mtos := make([]reflect.StructField, 0)
-	for i := 0; i < objectType.NumField(); i++ {
-		field := objectType.Field(i)
-		if IsEntity(field.Type) {
+	columns := columnFields(objectType)
+	for i := range columns {
+		field := columns[i]
+		if IsRelation(field.Type) {
 			mtos = append(mtos, field)
 		}
 	}
@@ -375,26 +713,29 @@ func (o *Trx) buildSelectFieldsForTemplate(template interface{}, path string) st
 	objectType := reflect.TypeOf(template)
 	fields := make([]reflect.StructField, 0)
 	mtos := make([]reflect.StructField, 0)
-	for i := 0; i < objectType.NumField(); i++ {
-		field := objectType.Field(i)
-		if IsEntity(field.Type) {
+	columns := columnFields(objectType)
+	for i := range columns {
+		field := columns[i]
+		if IsRelation(field.Type) {
 			mtos = append(mtos, field)
 		} else {
 			fields = append(fields, field)
 		}
 	}
 	sql := o.buildFieldsSelect(fields, path)
-	sql += o.buildMtoFieldsSelect(mtos, path)
+	sql += o.buildMtoFieldsSelect(mtos, path, nil, false)
 	return sql
 }
 
-func (o *Trx) createStmt(sql string) *sql.Stmt {
+func (o *Trx) createStmt(ctx context.Context, sql string) (*sql.Stmt, error) {
 	o.mux.Lock()
 	defer o.mux.Unlock()
-	stmt, err := o.tx.Prepare(sql)
-	tkt.CheckErr(err)
+	stmt, err := o.tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
 	o.stmtMap[sql] = stmt
-	return stmt
+	return stmt, nil
 }
 
 func (o *Trx) checkMaps() {
@@ -409,45 +750,70 @@ func (o *Trx) checkMaps() {
 	}
 }
 
-func (o *Trx) readBufferForType(buffer []interface{}, objectType reflect.Type, offset int) (*reflect.Value, int) {
+func (o *Trx) readBufferForType(buffer []interface{}, objectType reflect.Type, offset int, plan *fetchPlan) (*reflect.Value, int, error) {
 
-	ppId := buffer[offset].(**int64)
-	pId := *ppId
-	if pId == nil {
-		t := o.countFieldsDeep(objectType)
-		return nil, offset + t
+	id := buffer[offset].(*idBuffer)
+	if !id.valid {
+		t := o.countFieldsDeep(objectType, plan)
+		return nil, offset + t, nil
 	}
 	objectValue := reflect.New(objectType).Elem()
 	idField := objectValue.Field(0)
-	idField.Set(reflect.ValueOf(*pId))
-	mtos := make([]reflect.Value, 0)
-	var i int
+	idField.Set(id.value)
+	mtos := make([]reflect.StructField, 0)
+	columns := columnFields(objectType)
 	vi := offset + 1
-	for i = 1; i < objectValue.NumField(); i++ {
-		of := objectValue.Field(i)
-		if IsEntity(of.Type()) {
-			mtos = append(mtos, of)
+	for i := 1; i < len(columns); i++ {
+		of := objectValue.FieldByIndex(columns[i].Index)
+		if IsRelation(of.Type()) {
+			mtos = append(mtos, columns[i])
 		} else {
-			v := buffer[vi].(*interface{})
-			of.Set(reflect.ValueOf(*v))
+			v := reflect.ValueOf(buffer[vi]).Elem()
+			if IsOptional(of.Type()) {
+				of.Set(v)
+			} else if !v.IsNil() {
+				of.Set(v.Elem())
+			}
+			if IsReference(of.Type()) {
+				bindReference(of, o)
+			}
 			vi++
 		}
 	}
 	for j := range mtos {
-		mto := mtos[j]
-		var child interface{}
-		child, vi = o.readBufferForType(buffer, mto.Type(), vi)
-		mto.Set(*child.(*reflect.Value))
+		mto := objectValue.FieldByIndex(mtos[j].Index)
+		name := mtos[j].Name
+		var child *reflect.Value
+		if plan.fetches(name) {
+			var err error
+			child, vi, err = o.readBufferForType(buffer, entityType(mto.Type()), vi, plan.child(name))
+			if err != nil {
+				return nil, vi, err
+			}
+		} else {
+			child = o.readStub(buffer[vi], entityType(mto.Type()))
+			vi++
+		}
+		if child == nil {
+			continue
+		}
+		if IsOptional(mto.Type()) {
+			mto.Set(child.Addr())
+		} else {
+			mto.Set(*child)
+		}
 	}
-	return &objectValue, vi
+	o.snapshot(objectValue)
+	return &objectValue, vi, nil
 }
 
-func (o *Trx) countFieldsDeep(objectType reflect.Type) int {
+func (o *Trx) countFieldsDeep(objectType reflect.Type, plan *fetchPlan) int {
 	t := 0
-	for i := 0; i < objectType.NumField(); i++ {
-		f := objectType.Field(i)
-		if IsEntity(f.Type) {
-			t += o.countFieldsDeep(f.Type)
+	columns := columnFields(objectType)
+	for i := range columns {
+		f := columns[i]
+		if IsRelation(f.Type) && plan.fetches(f.Name) {
+			t += o.countFieldsDeep(entityType(f.Type), plan.child(f.Name))
 		} else {
 			t++
 		}
@@ -455,100 +821,143 @@ func (o *Trx) countFieldsDeep(objectType reflect.Type) int {
 	return t
 }
 
-func (o *Trx) buildReadBufferForType(objectType reflect.Type) []interface{} {
+func (o *Trx) buildReadBufferForType(objectType reflect.Type, plan *fetchPlan) []interface{} {
 	buffer := o.buildStaticFieldBuffer(objectType)
 	mtos := o.buildMtoList(objectType)
 	for i := range mtos {
 		mto := mtos[i]
-		mtoType := mto.Type
-		buffer = append(buffer, o.buildReadBufferForType(mtoType)...)
+		mtoType := entityType(mto.Type)
+		if plan.fetches(mto.Name) {
+			buffer = append(buffer, o.buildReadBufferForType(mtoType, plan.child(mto.Name))...)
+		} else {
+			buffer = append(buffer, newIdBuffer(mtoType.Field(0).Type))
+		}
 	}
 	return buffer
 }
 
+// readStub builds an unfetched relation holding only the id read from the
+// foreign key.
+func (o *Trx) readStub(value interface{}, objectType reflect.Type) *reflect.Value {
+	id := value.(*idBuffer)
+	if !id.valid {
+		return nil
+	}
+	stub := reflect.New(objectType).Elem()
+	stub.Field(0).Set(id.value)
+	return &stub
+}
+
 func (o *Trx) buildStaticFieldBuffer(objectType reflect.Type) []interface{} {
 	buffer := make([]interface{}, 0)
-	var id *int64
-	buffer = append(buffer, &id)
-	for i := 1; i < objectType.NumField(); i++ {
-		field := objectType.Field(i)
-		if !IsEntity(field.Type) {
-			i := reflect.New(field.Type).Interface()
-			buffer = append(buffer, &i)
+	buffer = append(buffer, newIdBuffer(objectType.Field(0).Type))
+	columns := columnFields(objectType)
+	for i := 1; i < len(columns); i++ {
+		field := columns[i]
+		if IsRelation(field.Type) {
+			continue
+		}
+		if IsOptional(field.Type) {
+			buffer = append(buffer, reflect.New(field.Type).Interface())
+		} else {
+			buffer = append(buffer, reflect.New(reflect.PtrTo(field.Type)).Interface())
 		}
 	}
 	return buffer
 }
 
-func (o *Trx) buildQuerySql(objectType reflect.Type) string {
+func (o *Trx) buildQuerySql(objectType reflect.Type, key string, plan *fetchPlan, scoped bool) string {
 	o.mux.Lock()
 	defer o.mux.Unlock()
 	fields := make([]reflect.StructField, 0)
 	mtos := make([]reflect.StructField, 0)
-	for i := 0; i < objectType.NumField(); i++ {
-		field := objectType.Field(i)
-		if IsEntity(field.Type) {
+	columns := columnFields(objectType)
+	for i := range columns {
+		field := columns[i]
+		if IsRelation(field.Type) {
 			mtos = append(mtos, field)
 		} else {
 			fields = append(fields, field)
 		}
 	}
 	sql := "select " + o.buildFieldsSelect(fields, "o")
-	s := o.buildMtoFieldsSelect(mtos, "o")
+	s := o.buildMtoFieldsSelect(mtos, "o", plan, scoped)
 	sql += s
-	name := FqTableName(objectType)
-	sql += " from " + name + " o"
-	s = o.buildMtoJoins(mtos, "o")
+	sql += " from " + o.scopedTable(objectType, scoped) + " o"
+	s = o.buildMtoJoins(mtos, "o", false, plan, scoped)
 	sql += s
-	o.queryMap[objectType.Name()] = sql
+	o.queryMap[key] = sql
 	return sql
 }
 
-func (o *Trx) buildMtoFieldsSelect(mtos []reflect.StructField, path string) string {
+func (o *Trx) buildMtoFieldsSelect(mtos []reflect.StructField, path string, plan *fetchPlan, scoped bool) string {
 	sql := ""
 	for i := range mtos {
 		mto := mtos[i]
-		mtoType := mto.Type
+		if !plan.fetches(mto.Name) {
+			if o.scopedStub(mto, scoped) {
+				sql += ", " + path + "_" + mto.Name + "." + o.column(entityType(mto.Type).Field(0))
+			} else {
+				sql += ", " + path + "." + o.column(mto)
+			}
+			continue
+		}
+		mtoType := entityType(mto.Type)
 		childMtos := make([]reflect.StructField, 0)
 		childPath := path + "_" + mto.Name
-		for j := 0; j < mtoType.NumField(); j++ {
-			field := mtoType.Field(j)
-			if IsEntity(field.Type) {
+		columns := columnFields(mtoType)
+		for j := range columns {
+			field := columns[j]
+			if IsRelation(field.Type) {
 				childMtos = append(childMtos, field)
 			} else {
 				sql += ", "
-				sql += fmt.Sprintf("%s.%s", childPath, field.Name)
+				sql += childPath + "." + o.column(field)
 			}
 		}
-		s := o.buildMtoFieldsSelect(childMtos, childPath)
+		s := o.buildMtoFieldsSelect(childMtos, childPath, plan.child(mto.Name), scoped)
 		sql += s
 	}
 	return sql
 }
 
-func (o *Trx) buildMtoJoins(mtos []reflect.StructField, path string) string {
+func (o *Trx) buildMtoJoins(mtos []reflect.StructField, path string, outer bool, plan *fetchPlan, scoped bool) string {
 	sql := ""
 	for i := range mtos {
 		mto := mtos[i]
-		mtoType := mto.Type
+		fetched := plan.fetches(mto.Name)
+		if !fetched && !o.scopedStub(mto, scoped) {
+			continue
+		}
+		mtoType := entityType(mto.Type)
 		childPath := path + "_" + mto.Name
+		childOuter := outer || IsOptional(mto.Type)
 		if i > 0 {
 			sql += "\r\n"
 		} else {
 			sql += " "
 		}
-		name := FqTableName(mto.Type)
-		sql += fmt.Sprintf("join %s %s on %s.id = %s.%s_id", name, childPath, childPath, path, mto.Name)
+		join := "join"
+		if childOuter {
+			join = "left outer join"
+		}
+		idField, _ := mtoType.FieldByName("Id")
+		sql += fmt.Sprintf("%s %s %s on %s.%s = %s.%s", join, o.table(mtoType), childPath, childPath, o.column(idField), path, o.column(mto))
+		sql += o.scopedJoin(mtoType, childPath, scoped)
+		if !fetched {
+			continue
+		}
 		childMtos := make([]reflect.StructField, 0)
-		for j := 0; j < mtoType.NumField(); j++ {
-			field := mtoType.Field(j)
-			if IsEntity(field.Type) {
+		columns := columnFields(mtoType)
+		for j := range columns {
+			field := columns[j]
+			if IsRelation(field.Type) {
 				childMtos = append(childMtos, field)
 			}
 		}
 		if len(childMtos) > 0 {
 			var s string
-			s = o.buildMtoJoins(childMtos, childPath)
+			s = o.buildMtoJoins(childMtos, childPath, childOuter, plan.child(mto.Name), scoped)
 			sql += s
 		}
 	}
@@ -562,8 +971,16 @@ func (o *Trx) buildFieldsSelect(fields []reflect.StructField, path string) strin
 			s += ", "
 		}
 		field := fields[i]
-		s += fmt.Sprintf("%s.%s", path, field.Name)
+		s += path + "." + o.column(field)
 	}
 	return s
 }
 
+func (o *Trx) table(objectType reflect.Type) string {
+	return quoteFq(o.dialect, FqTableName(objectType))
+}
+
+func (o *Trx) column(field reflect.StructField) string {
+	return o.dialect.Quote(ColumnName(field))
+}
+
@@ -0,0 +1,214 @@
+package srm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UpsertDialect is implemented by dialects able to insert or update a row in
+// a single statement.
+type UpsertDialect interface {
+	// Upsert extends a single row insert so that a row clashing on the
+	// conflict columns gets the update columns overwritten instead, and the
+	// version column, unless "", incremented. All identifiers are quoted,
+	// table being the one inserted into.
+	Upsert(table string, insertSql string, conflictColumns []string, updateColumns []string, versionColumn string) string
+}
+
+// Save inserts entity when its id is zero and updates it otherwise.
+func (o *Trx) Save(entity interface{}) error {
+	return o.SaveContext(context.Background(), entity)
+}
+
+func (o *Trx) SaveContext(ctx context.Context, entity interface{}) error {
+	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	if object.Field(0).IsZero() {
+		return o.PersistContext(ctx, entity)
+	}
+	return o.UpdateContext(ctx, entity)
+}
+
+// Upsert inserts entity or, when a row with the same values in the conflict
+// fields exists, updates that row's remaining columns. Conflict fields are
+// field names backed by a primary key or unique constraint, the id when none
+// is given. The id and version of entity end up being the ones of the stored
+// row, an update bumping the version without checking it. Lifecycle hooks are
+// not called.
+func (o *Trx) Upsert(entity interface{}, conflictFields ...string) error {
+	return o.UpsertContext(context.Background(), entity, conflictFields...)
+}
+
+func (o *Trx) UpsertContext(ctx context.Context, entity interface{}, conflictFields ...string) error {
+	o.checkMaps()
+	dialect, ok := o.dialect.(UpsertDialect)
+	if !ok {
+		return fmt.Errorf("dialect %s cannot upsert", o.dialect.Name())
+	}
+	object := reflect.Indirect(reflect.ValueOf(entity).Elem())
+	objectType := object.Type()
+	fields := columnFields(objectType)
+	conflicts := []reflect.StructField{fields[0]}
+	if len(conflictFields) > 0 {
+		conflicts = make([]reflect.StructField, len(conflictFields))
+		for i := range conflictFields {
+			field, ok := objectType.FieldByName(conflictFields[i])
+			if !ok || IsCollection(field.Type) {
+				return fmt.Errorf("%s has no column field %s", objectType.Name(), conflictFields[i])
+			}
+			conflicts[i] = field
+		}
+	}
+	if err := initVersion(object); err != nil {
+		return err
+	}
+	if err := stampCreated(object); err != nil {
+		return err
+	}
+	version, err := versionIndex(objectType)
+	if err != nil {
+		return err
+	}
+	idField := object.Field(0)
+	onId := len(conflicts) == 1 && conflicts[0].Index[0] == 0
+	if idField.IsZero() && onId {
+		// A fresh id cannot clash with anything. persist, unlike Persist,
+		// calls no hooks.
+		return o.persist(ctx, object)
+	}
+	fresh := idField.IsZero()
+	generator, err := IdGeneratorFor(objectType)
+	if err != nil {
+		return err
+	}
+	identity := fresh && isIdentity(generator)
+	if fresh && !identity {
+		id, err := generator.NextId(ctx, o, objectType)
+		if err != nil {
+			return err
+		}
+		if err := assignId(idField, id); err != nil {
+			return err
+		}
+	}
+	args := o.insertArgs(object)
+	if identity {
+		fields = fields[1:]
+		args = args[1:]
+	}
+	conflictColumns := make([]string, len(conflicts))
+	skip := map[string]bool{ColumnName(objectType.Field(0)): true}
+	versionColumn := ""
+	if version > 0 {
+		versionColumn = o.column(columnFields(objectType)[version])
+		skip[ColumnName(columnFields(objectType)[version])] = true
+	}
+	// A clash keeps the creation time of the stored row.
+	for _, i := range autoIndexes(objectType, "create") {
+		skip[ColumnName(columnFields(objectType)[i])] = true
+	}
+	for i := range conflicts {
+		conflictColumns[i] = o.column(conflicts[i])
+		skip[ColumnName(conflicts[i])] = true
+	}
+	updateColumns := make([]string, 0, len(fields))
+	for i := range fields {
+		if !skip[ColumnName(fields[i])] {
+			updateColumns = append(updateColumns, o.column(fields[i]))
+		}
+	}
+	sql := dialect.Upsert(o.table(objectType), o.buildBulkInsertSql(objectType, fields, 1), conflictColumns, updateColumns, versionColumn)
+	stmt, ok := o.stmtMap[sql]
+	if !ok {
+		stmt, err = o.createStmt(ctx, sql)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return o.dialect.Classify(err)
+	}
+	if !onId || version > 0 {
+		if err := o.readUpserted(ctx, object, conflicts, version); err != nil {
+			return err
+		}
+	}
+	o.snapshot(object)
+	return nil
+}
+
+// readUpserted reads back the id and version of the row an upsert landed on,
+// which differ from the entity's when it turned into an update.
+func (o *Trx) readUpserted(ctx context.Context, object reflect.Value, conflicts []reflect.StructField, version int) error {
+	objectType := object.Type()
+	conditions := make([]string, len(conflicts))
+	args := make([]interface{}, len(conflicts))
+	for i := range conflicts {
+		conditions[i] = o.column(conflicts[i]) + " = " + o.dialect.Placeholder(i+1)
+		of := object.FieldByIndex(conflicts[i].Index)
+		if IsRelation(of.Type()) {
+			args[i] = relationId(of)
+		} else {
+			args[i] = of.Interface()
+		}
+	}
+	columns := o.column(objectType.Field(0))
+	id := newIdBuffer(objectType.Field(0).Type)
+	buffer := []interface{}{id}
+	var current int64
+	if version > 0 {
+		columns += ", " + o.column(columnFields(objectType)[version])
+		buffer = append(buffer, &current)
+	}
+	sql := "select " + columns + " from " + o.table(objectType) + " where " + strings.Join(conditions, " and ")
+	if err := o.tx.QueryRowContext(ctx, sql, args...).Scan(buffer...); err != nil {
+		return o.dialect.Classify(err)
+	}
+	object.Field(0).Set(id.value)
+	if version > 0 {
+		object.FieldByIndex(columnFields(objectType)[version].Index).SetInt(current)
+	}
+	return nil
+}
+
+// onConflict qualifies the stored version with table, as both it and excluded
+// are in scope of the update.
+func onConflict(table string, insertSql string, conflictColumns []string, updateColumns []string, versionColumn string) string {
+	sql := insertSql + " on conflict (" + strings.Join(conflictColumns, ", ") + ")"
+	sets := make([]string, 0, len(updateColumns)+1)
+	for i := range updateColumns {
+		sets = append(sets, updateColumns[i]+" = excluded."+updateColumns[i])
+	}
+	if versionColumn != "" {
+		sets = append(sets, versionColumn+" = "+table+"."+versionColumn+" + 1")
+	}
+	if len(sets) == 0 {
+		return sql + " do nothing"
+	}
+	return sql + " do update set " + strings.Join(sets, ", ")
+}
+
+func (o Postgres) Upsert(table string, insertSql string, conflictColumns []string, updateColumns []string, versionColumn string) string {
+	return onConflict(table, insertSql, conflictColumns, updateColumns, versionColumn)
+}
+
+func (o SQLite) Upsert(table string, insertSql string, conflictColumns []string, updateColumns []string, versionColumn string) string {
+	return onConflict(table, insertSql, conflictColumns, updateColumns, versionColumn)
+}
+
+// MySQL updates on a clash with any unique key, whatever the conflict
+// columns.
+func (o MySQL) Upsert(table string, insertSql string, conflictColumns []string, updateColumns []string, versionColumn string) string {
+	if len(updateColumns) == 0 && versionColumn == "" {
+		updateColumns = conflictColumns[:1]
+	}
+	sets := make([]string, 0, len(updateColumns)+1)
+	for i := range updateColumns {
+		sets = append(sets, updateColumns[i]+" = values("+updateColumns[i]+")")
+	}
+	if versionColumn != "" {
+		sets = append(sets, versionColumn+" = "+versionColumn+" + 1")
+	}
+	return insertSql + " on duplicate key update " + strings.Join(sets, ", ")
+}
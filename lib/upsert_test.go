@@ -0,0 +1,36 @@
+package srm
+
+import (
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	insert := `insert into "t"("id", "code", "name", "version") values($1, $2, $3, $4)`
+	cases := []struct {
+		dialect  UpsertDialect
+		insert   string
+		conflict []string
+		update   []string
+		version  string
+		expected string
+	}{
+		{Postgres{}, insert, []string{`"code"`}, []string{`"name"`}, `"version"`,
+			insert + ` on conflict ("code") do update set "name" = excluded."name", "version" = "t"."version" + 1`},
+		{Postgres{}, insert, []string{`"code"`}, []string{`"name"`}, "",
+			insert + ` on conflict ("code") do update set "name" = excluded."name"`},
+		{Postgres{}, insert, []string{`"code"`, `"name"`}, nil, "",
+			insert + ` on conflict ("code", "name") do nothing`},
+		{SQLite{}, insert, []string{`"code"`}, []string{`"name"`}, `"version"`,
+			insert + ` on conflict ("code") do update set "name" = excluded."name", "version" = "t"."version" + 1`},
+		{MySQL{}, "insert into `t`(`id`, `code`, `name`) values(?, ?, ?)", []string{"`code`"}, []string{"`name`"}, "`version`",
+			"insert into `t`(`id`, `code`, `name`) values(?, ?, ?) on duplicate key update `name` = values(`name`), `version` = `version` + 1"},
+		{MySQL{}, "insert into `t`(`id`, `code`) values(?, ?)", []string{"`code`"}, nil, "",
+			"insert into `t`(`id`, `code`) values(?, ?) on duplicate key update `code` = values(`code`)"},
+	}
+	for _, c := range cases {
+		table := c.dialect.(Dialect).Quote("t")
+		if actual := c.dialect.Upsert(table, c.insert, c.conflict, c.update, c.version); actual != c.expected {
+			t.Errorf("%T: expected %q, got %q", c.dialect, c.expected, actual)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 	"math/big"
 	"strings"
+	"fmt"
 )
 
 type Joins struct {
@@ -64,12 +65,71 @@ func IsEntity(objectType reflect.Type) bool {
 	kind := objectType.Kind()
 	if kind == reflect.Struct {
 		f, ok := objectType.FieldByName("Id")
-		return ok && f.Type.Kind() == reflect.Int64
+		return ok && IsIdType(f.Type)
 	} else {
 		return false
 	}
 }
 
+func IsRelation(fieldType reflect.Type) bool {
+	return IsEntity(entityType(fieldType))
+}
+
+// IsCollection reports whether fieldType is a slice of entities, the shape of
+// a one-to-many field.
+func IsCollection(fieldType reflect.Type) bool {
+	return fieldType.Kind() == reflect.Slice && IsRelation(fieldType.Elem())
+}
+
+// columnFields returns the fields of objectType stored in its own table, which
+// are the exported ones except collections.
+func columnFields(objectType reflect.Type) []reflect.StructField {
+	fields := make([]reflect.StructField, 0, objectType.NumField())
+	for i := 0; i < objectType.NumField(); i++ {
+		field := objectType.Field(i)
+		if field.PkgPath == "" && !IsCollection(field.Type) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func IsOptional(fieldType reflect.Type) bool {
+	return fieldType.Kind() == reflect.Ptr
+}
+
+// NullableType returns the value type behind a pointer or a database/sql
+// Null wrapper such as sql.NullString, and whether fieldType is one of those.
+func NullableType(fieldType reflect.Type) (reflect.Type, bool) {
+	if fieldType.Kind() == reflect.Ptr {
+		return fieldType.Elem(), true
+	}
+	if fieldType.Kind() == reflect.Struct && fieldType.PkgPath() == "database/sql" && fieldType.NumField() == 2 {
+		valid, ok := fieldType.FieldByName("Valid")
+		if ok && valid.Index[0] == 1 && valid.Type.Kind() == reflect.Bool {
+			return fieldType.Field(0).Type, true
+		}
+	}
+	return fieldType, false
+}
+
+func entityType(fieldType reflect.Type) reflect.Type {
+	if fieldType.Kind() == reflect.Ptr {
+		return fieldType.Elem()
+	}
+	return fieldType
+}
+
+func relationId(value reflect.Value) interface{} {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	return idValue(value.FieldByName("Id"))
+}
+
 func FqTableName(objectType reflect.Type) string {
 	name := strings.ToLower(objectType.Name())
 	idField, _ := objectType.FieldByName("Id")
@@ -79,4 +139,34 @@ func FqTableName(objectType reflect.Type) string {
 	} else {
 		return name
 	}
-}
\ No newline at end of file
+}
+
+func ColumnName(field reflect.StructField) string {
+	name := strings.ToLower(field.Name)
+	if IsRelation(field.Type) || IsReference(field.Type) {
+		return name + "_id"
+	}
+	return name
+}
+
+func quoteFq(dialect Dialect, name string) string {
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = dialect.Quote(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+func catch(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	f()
+	return nil
+}
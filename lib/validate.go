@@ -0,0 +1,188 @@
+package srm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type SchemaIssueKind string
+
+const (
+	MissingTable        SchemaIssueKind = "missing table"
+	MissingColumn       SchemaIssueKind = "missing column"
+	ExtraColumn         SchemaIssueKind = "extra column"
+	TypeMismatch        SchemaIssueKind = "type mismatch"
+	NullabilityMismatch SchemaIssueKind = "nullability mismatch"
+	MissingForeignKey   SchemaIssueKind = "missing foreign key"
+	ExtraForeignKey     SchemaIssueKind = "extra foreign key"
+)
+
+type SchemaIssue struct {
+	Table    string
+	Column   string
+	Kind     SchemaIssueKind
+	Expected string
+	Actual   string
+	spec     columnSpec
+	key      ForeignKeyInfo
+}
+
+func (o SchemaIssue) String() string {
+	name := o.Table
+	if o.Column != "" {
+		name += "." + o.Column
+	}
+	s := name + ": " + string(o.Kind)
+	if o.Expected != "" || o.Actual != "" {
+		s += fmt.Sprintf(" (expected %q, found %q)", o.Expected, o.Actual)
+	}
+	return s
+}
+
+type SchemaReport struct {
+	Issues []SchemaIssue
+}
+
+func (o *SchemaReport) Valid() bool {
+	return len(o.Issues) == 0
+}
+
+// Err returns nil for a valid schema and an error listing every issue
+// otherwise.
+func (o *SchemaReport) Err() error {
+	if o.Valid() {
+		return nil
+	}
+	messages := make([]string, len(o.Issues))
+	for i := range o.Issues {
+		messages[i] = o.Issues[i].String()
+	}
+	return errors.New("schema validation failed:\n" + strings.Join(messages, "\n"))
+}
+
+func (o *Mgr) ValidateSchema(templates ...interface{}) (*SchemaReport, error) {
+	trx, err := o.StartTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer trx.Rollback()
+	ctx := context.Background()
+	schemaDialect, ok := trx.dialect.(SchemaDialect)
+	if !ok {
+		return nil, fmt.Errorf("dialect %s cannot inspect the catalog", trx.dialect.Name())
+	}
+	report := SchemaReport{Issues: make([]SchemaIssue, 0)}
+	for i := range templates {
+		objectType := reflect.TypeOf(templates[i])
+		issues, err := o.inspectTable(ctx, trx, schemaDialect, objectType)
+		if err != nil {
+			return nil, err
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+	return &report, nil
+}
+
+func (o *Mgr) inspectTable(ctx context.Context, trx *Trx, schemaDialect SchemaDialect, objectType reflect.Type) ([]SchemaIssue, error) {
+	fqName := FqTableName(objectType)
+	schema, table := splitFq(fqName)
+	columns, err := schemaDialect.TableColumns(ctx, trx.tx, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return []SchemaIssue{{Table: fqName, Kind: MissingTable}}, nil
+	}
+	keys, err := schemaDialect.ForeignKeys(ctx, trx.tx, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	return compareTable(trx.dialect, schemaDialect, objectType, columns, keys), nil
+}
+
+func compareTable(dialect Dialect, schemaDialect SchemaDialect, objectType reflect.Type, columns []ColumnInfo, keys []ForeignKeyInfo) []SchemaIssue {
+	fqName := FqTableName(objectType)
+	specs := buildColumnSpecs(dialect, objectType)
+	expected := make(map[string]columnSpec)
+	for i := range specs {
+		expected[specs[i].name] = specs[i]
+	}
+	actual := make(map[string]ColumnInfo)
+	for i := range columns {
+		actual[strings.ToLower(columns[i].Name)] = columns[i]
+	}
+
+	issues := make([]SchemaIssue, 0)
+	for i := range keys {
+		k := keys[i]
+		spec, ok := expected[strings.ToLower(k.Column)]
+		if !ok || spec.refType == nil || !sameTable(spec.refType, k.RefSchema, k.RefTable) {
+			issues = append(issues, SchemaIssue{Table: fqName, Column: k.Column, Kind: ExtraForeignKey,
+				Actual: qualifiedName(k.RefSchema, k.RefTable) + "(" + k.RefColumn + ")", key: k})
+		}
+	}
+	for i := range specs {
+		spec := specs[i]
+		c, ok := actual[spec.name]
+		if !ok {
+			issues = append(issues, SchemaIssue{Table: fqName, Column: spec.name, Kind: MissingColumn,
+				Expected: spec.sqlType + nullability(spec.nullable), spec: spec})
+			continue
+		}
+		if schemaDialect.NormalizeType(spec.sqlType) != schemaDialect.NormalizeType(c.Type) {
+			issues = append(issues, SchemaIssue{Table: fqName, Column: spec.name, Kind: TypeMismatch,
+				Expected: spec.sqlType, Actual: c.Type, spec: spec})
+		}
+		if spec.nullable != c.Nullable {
+			issues = append(issues, SchemaIssue{Table: fqName, Column: spec.name, Kind: NullabilityMismatch,
+				Expected: nullableName(spec.nullable), Actual: nullableName(c.Nullable), spec: spec})
+		}
+	}
+	for i := range columns {
+		c := columns[i]
+		if _, ok := expected[strings.ToLower(c.Name)]; !ok {
+			issues = append(issues, SchemaIssue{Table: fqName, Column: c.Name, Kind: ExtraColumn,
+				Actual: c.Type + nullability(c.Nullable)})
+		}
+	}
+	for i := range specs {
+		spec := specs[i]
+		if spec.refType == nil || hasForeignKey(keys, spec) {
+			continue
+		}
+		issues = append(issues, SchemaIssue{Table: fqName, Column: spec.name, Kind: MissingForeignKey,
+			Expected: FqTableName(spec.refType) + "(" + spec.refColumn + ")", spec: spec})
+	}
+	return issues
+}
+
+func qualifiedName(schema string, table string) string {
+	if schema == "" {
+		return table
+	}
+	return schema + "." + table
+}
+
+func nullableName(nullable bool) string {
+	if nullable {
+		return "null"
+	}
+	return "not null"
+}
+
+func hasForeignKey(keys []ForeignKeyInfo, spec columnSpec) bool {
+	for i := range keys {
+		if strings.EqualFold(keys[i].Column, spec.name) && sameTable(spec.refType, keys[i].RefSchema, keys[i].RefTable) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameTable(objectType reflect.Type, schema string, table string) bool {
+	expectedSchema, expectedTable := splitFq(FqTableName(objectType))
+	return strings.EqualFold(expectedTable, table) && (expectedSchema == "" || strings.EqualFold(expectedSchema, schema))
+}
@@ -0,0 +1,80 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type validateMaster struct {
+	Id   int64
+	Name string
+}
+
+type validateDetail struct {
+	Id     int64
+	Master validateMaster
+	Note   *string
+}
+
+func catalogOf(dialect Dialect, objectType reflect.Type) ([]ColumnInfo, []ForeignKeyInfo) {
+	specs := buildColumnSpecs(dialect, objectType)
+	columns := make([]ColumnInfo, 0)
+	keys := make([]ForeignKeyInfo, 0)
+	for i := range specs {
+		columns = append(columns, ColumnInfo{Name: specs[i].name, Type: specs[i].sqlType, Nullable: specs[i].nullable})
+		if specs[i].refType != nil {
+			keys = append(keys, ForeignKeyInfo{Name: "fk_" + specs[i].name, Column: specs[i].name, RefTable: FqTableName(specs[i].refType), RefColumn: specs[i].refColumn})
+		}
+	}
+	return columns, keys
+}
+
+func issueKinds(issues []SchemaIssue) []string {
+	kinds := make([]string, len(issues))
+	for i := range issues {
+		kinds[i] = issues[i].Column + " " + string(issues[i].Kind)
+	}
+	return kinds
+}
+
+func TestCompareTable(t *testing.T) {
+	dialect := Postgres{}
+	objectType := reflect.TypeOf(validateDetail{})
+	columns, keys := catalogOf(dialect, objectType)
+	if issues := compareTable(dialect, dialect, objectType, columns, keys); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issueKinds(issues))
+	}
+
+	columns, keys = catalogOf(dialect, objectType)
+	columns[1].Type = "INT8"
+	if issues := compareTable(dialect, dialect, objectType, columns, keys); len(issues) != 0 {
+		t.Errorf("expected aliases to match, got %v", issueKinds(issues))
+	}
+
+	columns, keys = catalogOf(dialect, objectType)
+	columns[2].Nullable = false
+	columns[2].Type = "text"
+	columns = append(columns, ColumnInfo{Name: "legacy", Type: "integer", Nullable: true})
+	keys = nil
+	expected := []string{
+		"note type mismatch",
+		"note nullability mismatch",
+		"legacy extra column",
+		"master_id missing foreign key",
+	}
+	if actual := issueKinds(compareTable(dialect, dialect, objectType, columns, keys)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	columns, keys = catalogOf(dialect, objectType)
+	columns = columns[:2]
+	keys[0].RefTable = "other"
+	expected = []string{
+		"master_id extra foreign key",
+		"note missing column",
+		"master_id missing foreign key",
+	}
+	if actual := issueKinds(compareTable(dialect, dialect, objectType, columns, keys)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
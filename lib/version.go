@@ -0,0 +1,54 @@
+package srm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrStaleObject is returned, wrapped, by Update and Delete when the row of a
+// versioned entity was changed or removed since the entity was read.
+var ErrStaleObject = errors.New("stale object")
+
+// versionIndex returns the index within columnFields of the field tagged
+// `version:"true"`, or -1 when objectType is not versioned.
+func versionIndex(objectType reflect.Type) (int, error) {
+	fields := columnFields(objectType)
+	for i := 1; i < len(fields); i++ {
+		if fields[i].Tag.Get("version") != "true" {
+			continue
+		}
+		if fields[i].Type.Kind() != reflect.Int64 {
+			return -1, fmt.Errorf("%s.%s: version fields must be int64", objectType.Name(), fields[i].Name)
+		}
+		return i, nil
+	}
+	return -1, nil
+}
+
+// initVersion sets the version of a new entity to 1.
+func initVersion(object reflect.Value) error {
+	version, err := versionIndex(object.Type())
+	if err != nil || version < 0 {
+		return err
+	}
+	of := object.FieldByIndex(columnFields(object.Type())[version].Index)
+	if of.Int() == 0 {
+		of.SetInt(1)
+	}
+	return nil
+}
+
+// checkVersion turns an update or delete of a versioned entity that matched
+// no row into ErrStaleObject.
+func checkVersion(r sql.Result, object reflect.Value, version int64) error {
+	count, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %s %v at version %d", ErrStaleObject, object.Type().Name(), idValue(object.Field(0)), version)
+	}
+	return nil
+}